@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// reportChecks prints the result of a diagnostic command, in the format chosen with --output, and exits with status 1
+// if any of its checks failed, so that the command can be used in scripts.
+// The text output lists the checks one per line, while the json one is the whole result.
+func reportChecks(outputFormat string, result any, checks []lib.CheckResult) {
+	switch outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+	case "text":
+		for _, check := range checks {
+			status := "PASS"
+			if !check.Pass {
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("%s  %s", status, check.Name)
+			if check.Details != "" {
+				line += ": " + check.Details
+			}
+			fmt.Println(line)
+		}
+	default:
+		log.Fatalf("unknown output format: %s", outputFormat)
+	}
+
+	for _, check := range checks {
+		if !check.Pass {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// cookieEntry is the cookie of a publication in the file given with --cookies-file.
+type cookieEntry struct {
+	Name  cookieName `json:"name"`
+	Value string     `json:"value"`
+}
+
+// loadCookiesFile reads the JSON file at path, which maps the hosts of publications to their cookie, e.g.
+//
+//	{"example.substack.com": {"name": "substack.sid", "value": "..."}}
+//
+// The hosts can also be given as the url of the publication.
+// Every entry is validated, so that a misconfigured publication is reported before starting instead of being downloaded as previews.
+func loadCookiesFile(path string) (map[string]*http.Cookie, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the cookies file: %w", err)
+	}
+	var entries map[string]cookieEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("invalid cookies file %s: %w", path, err)
+	}
+
+	cookies := make(map[string]*http.Cookie, len(entries))
+	for host, entry := range entries {
+		if strings.Contains(host, "://") {
+			u, err := url.Parse(host)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host %q in the cookies file: %w", host, err)
+			}
+			host = u.Hostname()
+		}
+		if host == "" {
+			return nil, fmt.Errorf("invalid host in the cookies file: it cannot be empty")
+		}
+		if err := entry.Name.Set(string(entry.Name)); err != nil {
+			return nil, fmt.Errorf("invalid cookie for %s: %w", host, err)
+		}
+		if entry.Value == "" {
+			return nil, fmt.Errorf("invalid cookie for %s: its value is empty", host)
+		}
+		cookies[host] = &http.Cookie{Name: string(entry.Name), Value: entry.Value}
+	}
+	return cookies, nil
+}
+
+// loadCookieFile reads the cookie given with --cookie-file, from either a Netscape cookie jar,
+// as exported by browser extensions and used by curl, or a file holding a single name=value line.
+// A cookie jar can hold other cookies, which are ignored, but the cookie must be named substack.sid or connect.sid.
+func loadCookieFile(path string) (*http.Cookie, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the cookie file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		// the cookie jars mark the HttpOnly cookies, like the Substack session, with a prefix
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name, value string
+		if fields := strings.Split(line, "\t"); len(fields) == 7 {
+			// domain, subdomains, path, secure, expiration, name, value
+			name, value = fields[5], fields[6]
+			if name != string(substackSid) && name != string(connectSid) {
+				continue
+			}
+		} else {
+			var found bool
+			name, value, found = strings.Cut(line, "=")
+			if !found {
+				return nil, fmt.Errorf("invalid cookie file %s: expected a Netscape cookie jar or a name=value line", path)
+			}
+			name = strings.TrimSpace(name)
+		}
+
+		var c cookieName
+		if err := c.Set(name); err != nil {
+			return nil, fmt.Errorf("invalid cookie in %s: %w", path, err)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("invalid cookie in %s: its value is empty", path)
+		}
+		return &http.Cookie{Name: name, Value: value}, nil
+	}
+	return nil, fmt.Errorf("no %s or %s cookie found in %s", substackSid, connectSid, path)
+}
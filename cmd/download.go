@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,19 +17,215 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// coversFolder is the folder, in the output folder, where --cover-only downloads the covers and writes their gallery.
+const coversFolder = "covers"
+
+// minFreeSpace is the minimum free space, in bytes, required in the output folder before starting a download.
+const minFreeSpace = 50 << 20
+
 // downloadCmd represents the download command
 var (
-	downloadUrl  string
-	format       string
-	outputFolder string
-	dryRun       bool
-	downloadCmd  = &cobra.Command{
+	downloadUrl   string
+	format        string
+	outputFolder  string
+	dryRun        bool
+	withComments  bool
+	commentsOnly  bool
+	commentFormat string
+	commentsConc  int
+	requireCookie bool
+	frontMatter   bool
+	rewriteDomain []string
+	emailVersion  bool
+	estimate      bool
+	estimateCount int
+	minimal       bool
+	stripStyling  bool
+	sanitize      bool
+	fullHTML      bool
+	baseHref      string
+	preserveMtime bool
+	skipGated     bool
+	flatten       bool
+	saveRaw       bool
+	deduplicate   bool
+	hugo          bool
+	jekyll        bool
+	sitePreset    lib.FrontMatterPreset
+	selfContained bool
+	asciiNames    bool
+	transcript    bool
+	preferReqUrl  bool
+	validateLinks bool
+	maxPostsRun   int
+	followChain   bool
+	compress      string
+	postToc       bool
+	prune         bool
+	bodySelector  string
+	writeFailures bool
+	exportPath    string
+	ereader       bool
+	epubBook      bool
+	coverOnly     bool
+	includeCover  bool
+	warmUpFirst   bool
+	jsonlPath     string
+	downloadAudio bool
+	verifySums    bool
+	outputTmpl    string
+	writeIndex    bool
+	indexMarkdown bool
+	exportRW      bool
+	readwiseToken string
+	// covers are the covers downloaded with --cover-only, listed in the gallery
+	covers []lib.Cover
+	// postsExport is where the posts are written with --jsonl-output, instead of one file per post
+	postsExport *lib.PostsExporter
+	// commentsExport is where the comments are exported with --export-comments, during an archive run
+	commentsExport *lib.CommentsExporter
+	// danglingCount is the number of dangling references found with --validate-links
+	danglingCount int
+	downloadCmd   = &cobra.Command{
 		Use:   "download",
 		Short: "Download individual posts or the entire public archive",
 		Long:  `You can provide the url of a single post or the main url of the Substack you want to download.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			startTime := time.Now()
 
+			extractor.CommentsConcurrency = commentsConc
+			extractor.BodySelector = bodySelector
+			extractor.VerifyChecksums = verifySums
+			extractor.KeepRaw = saveRaw
+
+			write := writePost
+			if commentsOnly {
+				write = writeComments
+			}
+			if coverOnly {
+				write = downloadCover
+			}
+
+			if jsonlPath != "" {
+				if commentsOnly || coverOnly || epubBook {
+					log.Fatalln("--jsonl-output writes the posts to a single file: it cannot be used with --comments-only, --cover-only and --epub-book")
+				}
+				write = exportPost
+			}
+
+			if exportRW {
+				if readwiseToken == "" {
+					log.Fatalln("--export-readwise needs your Readwise access token, given with --readwise-token")
+				}
+				if commentsOnly || coverOnly {
+					log.Fatalln("--export-readwise saves the downloaded posts: it cannot be used with --comments-only and --cover-only")
+				}
+				readwise = lib.NewReadwiseClient(readwiseToken, fetcher.Client)
+				write = withReadwise(write)
+			}
+
+			if err := validateOutputTemplate(outputTmpl); err != nil {
+				log.Fatalln(err)
+			}
+			rewrites, err := parseDomainRewrites(rewriteDomain)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			domainRewrites = rewrites
+			if (hugo || jekyll) && cmd.Flags().Changed("output-template") {
+				log.Fatalln("--hugo and --jekyll follow the content layout of the static site generator: --output-template is not supported with them")
+			}
+			if prune && !strings.Contains(outputTmpl, "{slug}") {
+				log.Fatalln("--prune matches the local posts by their slug: the --output-template must include {slug}")
+			}
+			if indexMarkdown {
+				writeIndex = true
+			}
+			if writeIndex && (commentsOnly || coverOnly || epubBook || jsonlPath != "") {
+				log.Fatalln("--index lists the post files: it cannot be used with --comments-only, --cover-only, --epub-book and --jsonl-output")
+			}
+
+			if hugo || jekyll {
+				if cmd.Flags().Changed("format") && format != "md" {
+					log.Fatalf("--hugo and --jekyll write md posts: --format %s is not supported with them", format)
+				}
+				format = "md"
+				sitePreset = lib.FrontMatterHugo
+				if jekyll {
+					sitePreset = lib.FrontMatterJekyll
+				}
+			}
+
+			switch compress {
+			case "", "gzip":
+			default:
+				log.Fatalf("unknown compression: %s", compress)
+			}
+
+			if epubBook {
+				if cmd.Flags().Changed("format") && format != "epub" {
+					log.Fatalf("--epub-book writes an epub book: --format %s is not supported with it", format)
+				}
+				format = "epub"
+			}
+
+			if ereader {
+				if cmd.Flags().Changed("format") && format != "html" {
+					log.Fatalf("--ereader writes html posts: --format %s is not supported with it", format)
+				}
+				format = "html"
+			}
+
+			if selfContained && format != "html" {
+				log.Fatalf("--self-contained requires the html format, not %s", format)
+			}
+
+			switch commentFormat {
+			case "", "json", "html", "md", "txt", "org":
+			default:
+				log.Fatalf("unknown comment format: %s", commentFormat)
+			}
+
+			if prune && (beforeDate != "" || afterDate != "") {
+				log.Fatalln("--prune needs the whole archive: it cannot be used with --before and --after")
+			}
+
+			if !dryRun && !estimate {
+				if err := checkOutputFolder(outputFolder); err != nil {
+					log.Fatalln(err)
+				}
+				if jsonlPath != "" {
+					var err error
+					if maxPostsRun > 0 {
+						// each run exports its batch of posts
+						postsExport, err = lib.OpenPostsExporter(jsonlPath)
+					} else {
+						postsExport, err = lib.NewPostsExporter(jsonlPath)
+					}
+					if err != nil {
+						log.Fatalln(err)
+					}
+					defer postsExport.Close()
+				}
+			}
+
+			if requireCookie {
+				if err := checkAuthentication(downloadUrl); err != nil {
+					log.Fatalln(err)
+				}
+			}
+
+			if lib.IsNoteURL(downloadUrl) {
+				if dryRun {
+					fmt.Println("Dry run, exiting...")
+					return
+				}
+				if err := downloadNote(downloadUrl); err != nil {
+					log.Fatalln(err)
+				}
+				return
+			}
+
 			// if url contains "/p/", we are downloading a single post
 			if strings.Contains(downloadUrl, "/p/") {
 				if verbose {
@@ -38,22 +238,50 @@ var (
 				if (beforeDate != "" || afterDate != "") && verbose {
 					fmt.Println("Warning: --before and --after flags are ignored when downloading a single post")
 				}
+				if prune && verbose {
+					fmt.Println("Warning: --prune is ignored when downloading a single post")
+				}
 
-				post, err := extractor.ExtractPost(ctx, downloadUrl)
+				posts := make([]lib.Post, 1)
+				var err error
+				if followChain {
+					posts, err = extractor.ExtractChain(ctx, downloadUrl)
+				} else {
+					posts[0], err = extractor.ExtractPost(ctx, downloadUrl)
+				}
 				if err != nil {
 					log.Fatalln(err)
 				}
+				for _, post := range posts {
+					reportPageCleanup(post)
+				}
 				downloadTime := time.Since(startTime)
 				if verbose {
 					fmt.Printf("Downloaded post %s in %s\n", downloadUrl, downloadTime)
+					if followChain {
+						fmt.Printf("Found %d posts in the chain\n", len(posts))
+					}
 				}
 
-				path := makePath(post, outputFolder, format)
-				if verbose {
-					fmt.Printf("Writing post to file %s\n", path)
+				if writeIndex {
+					pubUrl, _ := publicationRoot(downloadUrl)
+					if err := loadArchiveIndex(""); err != nil {
+						log.Fatalln(err)
+					}
+					if archiveIndex.Title == "" {
+						archiveIndex.Title = publicationHost(pubUrl)
+					}
+				}
+				for _, post := range posts {
+					if err := write(post); err != nil {
+						log.Fatalln(err)
+					}
+					indexPost(post)
+				}
+				writeArchiveIndex()
+				if validateLinks {
+					fmt.Println("Found", danglingCount, "dangling references")
 				}
-
-				post.WriteToFile(path, format)
 
 				if verbose {
 					fmt.Println("Done in ", time.Since(startTime))
@@ -61,8 +289,24 @@ var (
 			} else {
 				// we are downloading the entire archive
 				var downloadedPostsCount int
+				pubUrl, err := publicationRoot(downloadUrl)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				if verbose {
+					fmt.Printf("Main website: %s\n", pubUrl)
+				}
+				if warmUpFirst {
+					if err := warmUp(pubUrl); err != nil && verbose {
+						fmt.Println("Error warming up:", err)
+					}
+				}
+				if prune {
+					// a truncated sitemap would get valid posts pruned: the archive API completes it
+					extractor.UseArchiveAPI = true
+				}
 				dateFilterfunc := makeDateFilterFunc(beforeDate, afterDate)
-				urls, err := extractor.GetAllPostsURLs(ctx, downloadUrl, dateFilterfunc)
+				urls, err := extractor.GetAllPostsURLs(ctx, pubUrl, dateFilterfunc)
 				urlsCount := len(urls)
 				if err != nil {
 					log.Fatalln(err)
@@ -76,53 +320,235 @@ var (
 				if verbose {
 					fmt.Printf("Found %d posts\n", urlsCount)
 				}
+				if estimate {
+					if err := estimateArchive(urls, estimateCount); err != nil {
+						log.Fatalln(err)
+					}
+					return
+				}
 				if dryRun {
 					fmt.Printf("Found %d posts\n", urlsCount)
 					fmt.Println("Dry run, exiting...")
 					return
 				}
-				urls, err = filterExistingPosts(urls, outputFolder, format)
+				pub, err := writePublication(pubUrl, urlsCount)
+				if err != nil && verbose {
+					fmt.Println("Error writing publication metadata:", err)
+				}
+				// with --epub-book, the book all the posts are added to, written once they are all downloaded
+				var book *lib.EPUBBook
+				if epubBook && !commentsOnly {
+					book = &lib.EPUBBook{Identifier: pubUrl, Title: pub.Name, Author: pub.AuthorName, Language: pub.Language}
+					if book.Title == "" {
+						book.Title = publicationHost(pubUrl)
+					}
+					write = withReadwise(addToBook(book))
+				}
+				if prune {
+					// the local posts missing from the listing were removed upstream, unless it was interrupted
+					if err := pruneStalePosts(urls); err != nil {
+						log.Fatalln("Error pruning stale posts:", err)
+					}
+				}
+				if writeIndex {
+					title := pub.Name
+					if title == "" {
+						title = publicationHost(pubUrl)
+					}
+					if err := loadArchiveIndex(title); err != nil {
+						log.Fatalln(err)
+					}
+					// also on the early returns: the posts written so far, or removed by the prune, are reflected in it
+					defer writeArchiveIndex()
+				}
+				if commentsOnly {
+					// only the posts already downloaded get their comments
+					urls, err = filterMissingPosts(urls, outputFolder, format)
+				} else if book == nil && !coverOnly && postsExport == nil {
+					urls, err = filterExistingPosts(urls, outputFolder, format)
+				}
 				if err != nil {
 					if verbose {
 						fmt.Println("Error filtering existing posts:", err)
 					}
 				}
+				if maxPostsRun > 0 {
+					// the books, the galleries and the exports leave no file per post: the runs continue from the recorded state
+					if err := loadResumeState(); err != nil {
+						log.Fatalln(err)
+					}
+					urls = filterProcessedPosts(urls)
+					defer saveResumeState()
+				}
 				if len(urls) == 0 {
 					if verbose {
 						fmt.Println("No new posts found, exiting...")
 					}
 					return
 				}
+				// with --max-posts-per-run, the posts left for the next runs, which will skip the ones downloaded by this one
+				var postponedCount int
+				if maxPostsRun > 0 && len(urls) > maxPostsRun {
+					postponedCount = len(urls) - maxPostsRun
+					urls = urls[:maxPostsRun]
+				}
+				if exportPath != "" {
+					commentsExport, err = lib.NewCommentsExporter(exportPath)
+					if err != nil {
+						log.Fatalln(err)
+					}
+					defer commentsExport.Close()
+				}
+				// with --deduplicate-posts, the posts already written in this run, by key, along with their url
+				writtenPosts := make(map[string]string)
+				var duplicatesCount int
+				// with --tag, the number of posts skipped because they have none of the tags
+				var untaggedCount int
+				// the number of posts which failed to download
+				var failedCount int
 				bar := progressbar.NewOptions(len(urls),
 					progressbar.OptionSetWidth(25),
 					progressbar.OptionSetDescription("downloading"),
 					progressbar.OptionShowBytes(true))
-				for result := range extractor.ExtractAllPosts(ctx, urls) {
+				extractCtx, cancelExtract := context.WithCancel(ctx)
+				defer cancelExtract()
+				extractAll := extractor.ExtractAllPosts
+				if deduplicate || book != nil || coverOnly {
+					// keep the same post of each set of duplicates across runs: the first listed one,
+					// and the chapters of the book and the covers of the gallery in the order of the archive
+					extractAll = extractor.ExtractAllPostsOrdered
+				}
+			posts:
+				for result := range extractAll(extractCtx, urls) {
 					select {
 					case <-ctx.Done():
-						log.Fatalln("context cancelled")
+						// the posts written so far are complete: report them, and write the book and the gallery with them
+						cancelExtract()
+						fmt.Println()
+						if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+							fmt.Println("Reached the maximum duration of", maxDuration, "- downloaded", downloadedPostsCount, "posts, out of", len(urls))
+						} else {
+							fmt.Println("Interrupted: downloaded", downloadedPostsCount, "posts, out of", len(urls))
+						}
+						break posts
 					default:
 					}
+					if errors.Is(result.Err, lib.ErrMaxRequests) {
+						cancelExtract()
+						fmt.Println()
+						fmt.Println("Reached the maximum number of requests: downloaded", downloadedPostsCount, "posts,", len(urls)-downloadedPostsCount-duplicatesCount-untaggedCount-failedCount, "left unprocessed")
+						// the posts written so far are complete: write the book and the gallery with them
+						break posts
+					}
 					if result.Err != nil {
+						failedCount++
 						if verbose {
-							fmt.Printf("Error downloading post %s: %s\n", result.Post.CanonicalUrl, result.Err)
+							fmt.Printf("Error downloading post %s: %s\n", result.Url, result.Err)
 							fmt.Println("Skipping...")
 						}
+						if writeFailures {
+							if err := writeFailure(result.Url, result.Err); err != nil && verbose {
+								fmt.Printf("Error writing the failure of post %s: %s\n", result.Url, err)
+							}
+						}
 						continue
 					}
+					if len(tags) > 0 && !result.Post.HasTag(tags...) {
+						// the sitemap doesn't list the tags: the post had to be fetched to know them
+						bar.Add(1)
+						untaggedCount++
+						markProcessed(result.Url)
+						continue
+					}
+					if deduplicate {
+						key := postKey(result.Post)
+						if firstUrl, found := writtenPosts[key]; found {
+							if verbose {
+								fmt.Printf("Skipping post %s: it is the same post as %s\n", result.Post.CanonicalUrl, firstUrl)
+							}
+							bar.Add(1)
+							duplicatesCount++
+							markProcessed(result.Url)
+							continue
+						}
+						writtenPosts[key] = result.Post.CanonicalUrl
+					}
 					bar.Add(1)
-					downloadedPostsCount++
+					reportPageCleanup(result.Post)
 					if verbose {
 						fmt.Printf("Downloading post %s\n", result.Post.CanonicalUrl)
 					}
-					post := result.Post
-
-					path := makePath(post, outputFolder, format)
+					if err := write(result.Post); err != nil {
+						if errors.Is(err, lib.ErrCommentsGated) || errors.Is(err, lib.ErrReadwiseUnauthorized) {
+							// every other post of the publication would fail the same way
+							log.Fatalln(err)
+						}
+						failedCount++
+						if verbose {
+							fmt.Printf("Error writing post %s: %s\n", result.Post.CanonicalUrl, err)
+						}
+					} else {
+						downloadedPostsCount++
+						markProcessed(result.Url)
+						indexPost(result.Post)
+						if writeFailures {
+							// the post failed in a previous run: its placeholder is obsolete now
+							os.Remove(makeFailurePath(result.Url))
+						}
+					}
+					if commentsExport != nil && !withComments && !commentsOnly {
+						// the comments were not fetched to write the post: fetch them for the export only
+						if _, _, err := postComments(result.Post); err != nil {
+							if errors.Is(err, lib.ErrCommentsGated) {
+								log.Fatalln(err)
+							}
+							if verbose {
+								fmt.Printf("Error exporting the comments of post %s: %s\n", result.Post.CanonicalUrl, err)
+							}
+						}
+					}
+				}
+				if coverOnly {
+					path := filepath.Join(outputFolder, coversFolder, "index.html")
 					if verbose {
-						fmt.Printf("Writing post to file %s\n", path)
+						fmt.Printf("Writing the gallery of %d covers to file %s\n", len(covers), path)
+					}
+					title := pub.Name
+					if title == "" {
+						title = publicationHost(pubUrl)
+					}
+					if err := lib.WriteCoverGallery(path, title, covers); err != nil {
+						log.Fatalln(err)
 					}
-
-					post.WriteToFile(path, format)
+				}
+				if book != nil && book.Len() > 0 {
+					path := makeBookPath(pubUrl)
+					if verbose {
+						fmt.Printf("Writing %d posts to the book %s\n", book.Len(), path)
+					}
+					if err := book.WriteToFile(path); err != nil {
+						log.Fatalln(err)
+					}
+				}
+				if validateLinks {
+					fmt.Println()
+					fmt.Println("Found", danglingCount, "dangling references")
+				}
+				if postponedCount > 0 {
+					fmt.Println()
+					fmt.Println(postponedCount, "posts left for the next runs")
+				}
+				if duplicatesCount > 0 {
+					fmt.Println()
+					fmt.Println("Skipped", duplicatesCount, "duplicate posts")
+				}
+				if untaggedCount > 0 {
+					fmt.Println()
+					fmt.Println("Skipped", untaggedCount, "posts without the tags", strings.Join(tags, ", "))
+				}
+				if failedCount > 0 {
+					fmt.Println()
+					fmt.Println("Failed to download", failedCount, "posts (run with --verbose to see the errors)")
 				}
 				if verbose {
 					fmt.Println("Downloaded", downloadedPostsCount, "posts, out of", len(urls))
@@ -135,10 +561,68 @@ var (
 
 func init() {
 	downloadCmd.Flags().StringVarP(&downloadUrl, "url", "u", "", "Specify the Substack url")
-	downloadCmd.Flags().StringVarP(&format, "format", "f", "html", "Specify the output format (options: \"html\", \"md\", \"txt\"")
+	downloadCmd.Flags().StringVarP(&format, "format", "f", "html", "Specify the output format (options: \"html\", \"md\", \"txt\", \"org\", \"epub\")")
+	downloadCmd.Flags().BoolVar(&coverOnly, "cover-only", false, fmt.Sprintf("Only download the cover image of the posts, to %s/ in the download directory, along with an index.html gallery of them when downloading the entire archive", coversFolder))
+	downloadCmd.Flags().BoolVar(&downloadAudio, "download-audio", false, "Download the audio of podcast posts, and of the audio players in the posts, next to them as <post>.audio.<ext>, and make the posts play the local copy")
+	downloadCmd.Flags().BoolVar(&verifySums, "verify-checksums", false, "Record the SHA-256 sum of the downloaded audio files in a <file>.sha256 file next to them, and download again the existing ones which don't match it, e.g. after a crash, instead of skipping them")
+	downloadCmd.Flags().BoolVar(&includeCover, "include-cover", false, "Show the cover image at the top of the posts, unless it is already in their body (for txt posts, download it next to them instead, as <post>.cover.<ext>)")
+	downloadCmd.Flags().BoolVar(&epubBook, "epub-book", false, "When downloading the entire archive, write all its posts to a single epub book with a table of contents, named after the publication, instead of one file per post (implies --format epub)")
 	downloadCmd.Flags().StringVarP(&outputFolder, "output", "o", ".", "Specify the download directory")
+	downloadCmd.Flags().StringVar(&outputTmpl, "output-template", defaultOutputTemplate, "Specify the path of the post files in the download directory, with the placeholders {date} (YYYYMMDD_HHMMSS), {year}, {month}, {day}, {slug}, {title}, {id} and {ext}, e.g. {year}/{month}/{slug}.{ext}. Subdirectories are created as needed")
+	downloadCmd.Flags().BoolVar(&writeIndex, "index", false, fmt.Sprintf("Write an index.html in the download directory listing the downloaded posts, newest first, with their date and a link to their file. The index of the previous runs, stored in %s, is updated with the new posts", indexDataFile))
+	downloadCmd.Flags().BoolVar(&indexMarkdown, "index-md", false, "Also write the index as index.md (implies --index)")
 	downloadCmd.Flags().BoolVarP(&dryRun, "dry-run", "d", false, "Enable dry run")
+	downloadCmd.Flags().BoolVar(&fullHTML, "full-html", false, "Write html posts as complete HTML documents instead of fragments")
+	downloadCmd.Flags().StringVar(&baseHref, "base-href", "", "Add a <base href> with this URL to html posts (implies --full-html), so that relative paths resolve when the archive is served from a subpath")
+	downloadCmd.Flags().BoolVar(&flatten, "flatten", false, "Keep the links of txt posts as numbered references, listed at the end of each post")
+	downloadCmd.Flags().BoolVar(&exportRW, "export-readwise", false, "Also save each downloaded post (title, author, url and content) to your Readwise Reader library, through its API (requires --readwise-token)")
+	downloadCmd.Flags().StringVar(&readwiseToken, "readwise-token", "", "Your Readwise access token, from https://readwise.io/access_token, used by --export-readwise")
+	downloadCmd.Flags().StringVar(&jsonlPath, "jsonl-output", "", "Write all the posts to this JSON Lines file (e.g. posts.jsonl), one post per line with all its data and its body as plain text, instead of one file per post. The file is written anew on each run, unless with --max-posts-per-run")
+	downloadCmd.Flags().StringVar(&exportPath, "export-comments", "", "When downloading the entire archive, also append the comments of the downloaded posts to this JSON Lines file (e.g. comments.jsonl), one comment per line with its post")
+	downloadCmd.Flags().BoolVar(&writeFailures, "write-failures", false, "Write a <slug>.failed.txt placeholder, with the url and the error, for each post which fails to download, and remove it once the post is downloaded")
+	downloadCmd.Flags().StringVar(&bodySelector, "body-selector", "", "Specify the CSS selector of the post content in the page, used as the body of the posts whose page data has none (best effort, for nonstandard publications)")
+	downloadCmd.Flags().BoolVar(&prune, "prune", false, fmt.Sprintf("Move the local posts which are no longer in the archive, e.g. unpublished ones, to %s/ in the download directory, reporting each of them. The archive is also listed through its API (as with --use-api), and nothing is pruned if the listing is interrupted", trashFolder))
+	downloadCmd.Flags().BoolVar(&postToc, "post-toc", false, fmt.Sprintf("Add a table of contents at the top of the html and md posts with at least %d headings", lib.TOCMinHeadings))
+	downloadCmd.Flags().StringVar(&compress, "compress", "", "Compress the post files (options: \"gzip\"), adding the matching extension to their name (e.g. .html.gz)")
+	downloadCmd.Flags().BoolVar(&followChain, "follow-chain", false, "When downloading a single post, also download the posts chained to it as previous and next posts, e.g. to get a whole series")
+	downloadCmd.Flags().IntVar(&maxPostsRun, "max-posts-per-run", 0, fmt.Sprintf("Download at most this number of new posts, leaving the others for the next runs (0 for no limit). The processed posts are recorded in %s in the download directory, so that the next runs continue with the following ones, also with --epub-book, --cover-only, --jsonl-output and --comments-only. The --jsonl-output file is then appended to, while the --epub-book book and the --cover-only gallery only hold the posts of their run", resumeStateFile))
+	downloadCmd.Flags().BoolVar(&validateLinks, "validate-links", false, "Check that the local paths referenced by the html and md posts exist, and report the dangling ones")
+	downloadCmd.Flags().BoolVar(&preferReqUrl, "prefer-requested-url", false, "Name and attribute the posts after the url they were requested from, instead of their canonical url")
+	downloadCmd.Flags().BoolVar(&transcript, "include-transcript", false, "Append the transcript of podcast posts, when available")
+	downloadCmd.Flags().BoolVar(&asciiNames, "ascii-filenames", false, "Use only ASCII characters in the file names: accents are removed (é -> e) and other characters, like emoji, are replaced by dashes")
+	downloadCmd.Flags().BoolVar(&ereader, "ereader", false, "Write the posts for e-readers: complete html documents (implies --full-html) with only the article prose and images, as with --minimal but keeping the images, styled with serif fonts and large margins. Combined with --minimal, the images are removed too")
+	downloadCmd.Flags().BoolVar(&selfContained, "self-contained", false, "Write html posts as single files with no external dependencies: complete documents (implies --full-html) with inline styles and images embedded as data URIs")
+	downloadCmd.Flags().BoolVar(&hugo, "hugo", false, "Write md posts with Hugo front matter to content/posts/<slug>.md in the download directory")
+	downloadCmd.Flags().BoolVar(&jekyll, "jekyll", false, "Write md posts with Jekyll front matter to _posts/YYYY-MM-DD-<slug>.md in the download directory")
+	downloadCmd.Flags().BoolVar(&deduplicate, "deduplicate-posts", false, "Skip the posts already written in the same run under another slug, based on their id (or title, when missing)")
+	downloadCmd.Flags().BoolVar(&saveRaw, "save-raw", false, "Also save the raw JSON data each post is extracted from, to a separate <post>.raw.json file (with --sanitize-private-data, without the reader data, like their account and subscription)")
+	downloadCmd.Flags().BoolVar(&preserveMtime, "preserve-mtime", false, "Set the modification time of the downloaded posts to their publication date")
+	downloadCmd.Flags().BoolVar(&frontMatter, "front-matter", false, "Add the post metadata (title, date, last edit date, slug, canonical url, tags, aliases) as YAML front matter to md posts")
+	downloadCmd.Flags().StringArrayVar(&rewriteDomain, "rewrite-domain", nil, "Rewrite the canonical url and the links to posts from a domain to another, in the form old=new (e.g. example.substack.com=example.com). Can be repeated")
+	downloadCmd.Flags().BoolVar(&emailVersion, "email-version", false, "Save the version of the posts sent by email to the subscribers, when available, instead of the web version")
+	downloadCmd.Flags().BoolVar(&minimal, "minimal", false, "Keep only the article prose (headings, paragraphs, lists, blockquotes), removing widgets, media and footnotes")
+	downloadCmd.Flags().BoolVar(&stripStyling, "strip-classes", false, "Remove the class, style and data-* attributes of the Substack layout from the saved posts, keeping the image sources and link targets, for smaller files to restyle from scratch (by default they are kept)")
+	downloadCmd.Flags().BoolVar(&sanitize, "sanitize-private-data", false, "Remove reader-specific data (session tokens, referral codes) from the saved posts")
+	downloadCmd.Flags().BoolVar(&withComments, "comments", false, "Download the comments of each post")
+	downloadCmd.Flags().BoolVar(&skipGated, "skip-gated-comments", false, "Skip the comments that are only accessible to subscribers, instead of aborting, when the cookie doesn't grant access to them")
+	downloadCmd.Flags().BoolVar(&commentsOnly, "comments-only", false, "Only download the comments of the posts already in the download directory, without rewriting the posts")
+	downloadCmd.Flags().StringVar(&commentFormat, "comment-format", "", "Specify the comments output format (options: \"json\", \"html\", \"md\", \"txt\", \"org\"). When it differs from --format, comments are written to a separate <post>.comments.<format> file (default: same as --format)")
+	downloadCmd.Flags().BoolVar(&requireCookie, "require-cookie", false, "Abort if no cookie is provided or if it is not recognized, instead of downloading the previews of private posts")
+	downloadCmd.Flags().IntVar(&commentsConc, "comments-concurrency", 4, "Specify how many pages of comments to fetch at the same time (1 to fetch them one at a time)")
+	downloadCmd.Flags().BoolVar(&warmUpFirst, "warm-up", false, "Before downloading the entire archive, send a single request to the publication to report how the server answers (rate limits, CDN, cookie) with --verbose, and lower --rate if it asks to slow down or is behind Cloudflare")
+	downloadCmd.Flags().BoolVar(&estimate, "estimate", false, "Estimate the size of the archive and the number of requests from a sample of posts, then exit")
+	downloadCmd.Flags().IntVar(&estimateCount, "estimate-sample", 5, "Specify how many posts to sample for --estimate")
 	downloadCmd.MarkFlagRequired("url")
+	downloadCmd.MarkFlagsMutuallyExclusive("hugo", "jekyll")
+}
+
+// convertDate converts the post datetime to the YYYY-MM-DD format, or returns an empty string if it cannot be parsed.
+func convertDate(datetime string) string {
+	parsedTime, err := time.Parse(time.RFC3339, datetime)
+	if err != nil {
+		return ""
+	}
+	return parsedTime.Format("2006-01-02")
 }
 
 func convertDateTime(datetime string) string {
@@ -171,15 +655,543 @@ func parseURL(toTest string) (*url.URL, error) {
 	return u, err
 }
 
+// publicationRoot returns the main URL (scheme and host) of the publication of any of its pages,
+// e.g. https://example.substack.com/archive -> https://example.substack.com
+func publicationRoot(pageUrl string) (string, error) {
+	parsedURL, err := parseURL(pageUrl)
+	if err != nil {
+		return "", err
+	}
+	if parsedURL == nil {
+		return "", fmt.Errorf("invalid url: %s", pageUrl)
+	}
+	return fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host), nil
+}
+
+// makePath returns the path of the post file in the output folder, laid out with --output-template.
+// With --hugo and --jekyll, it follows the content layout of the static site generator instead.
 func makePath(post lib.Post, outputFolder string, format string) string {
-	return fmt.Sprintf("%s/%s_%s.%s", outputFolder, convertDateTime(post.PostDate), post.Slug, format)
+	slug := fileSlug(post.Slug)
+	switch sitePreset {
+	case lib.FrontMatterHugo:
+		return filepath.Join(outputFolder, "content", "posts", slug+".md")
+	case lib.FrontMatterJekyll:
+		return filepath.Join(outputFolder, "_posts", fmt.Sprintf("%s-%s.md", convertDate(post.PostDate), slug))
+	}
+	return fmt.Sprintf("%s/%s", outputFolder, renderOutputTemplate(outputTmpl, post, format))
+}
+
+// fileSlug returns the slug as used in file names, folded to ASCII with --ascii-filenames.
+func fileSlug(slug string) string {
+	if asciiNames {
+		return asciiFilename(slug)
+	}
+	return slug
+}
+
+// checkOutputFolder makes sure the output folder exists and is writable before starting a download,
+// so that problems surface right away instead of after extracting many posts.
+// When supported by the platform, it also checks that there is at least minFreeSpace bytes of free space.
+func checkOutputFolder(outputFolder string) error {
+	if err := os.MkdirAll(outputFolder, 0755); err != nil {
+		return fmt.Errorf("output folder %s cannot be created: %s", outputFolder, err)
+	}
+
+	f, err := os.CreateTemp(outputFolder, ".sbstck-dl-*")
+	if err != nil {
+		return fmt.Errorf("output folder %s is not writable: %s", outputFolder, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	free, err := freeDiskSpace(outputFolder)
+	if err != nil {
+		// the free space check is best-effort
+		if verbose {
+			fmt.Println("Skipping free disk space check:", err)
+		}
+		return nil
+	}
+	if free < minFreeSpace {
+		return fmt.Errorf("not enough free space in output folder %s: %d MB available, at least %d MB required", outputFolder, free>>20, minFreeSpace>>20)
+	}
+
+	return nil
+}
+
+// checkAuthentication makes sure a cookie is configured and that Substack recognizes it on the page at pageUrl.
+func checkAuthentication(pageUrl string) error {
+	u, err := parseURL(pageUrl)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return fmt.Errorf("invalid url: %s", pageUrl)
+	}
+	if fetcher.CookieFor(u.Hostname()) == nil {
+		return errors.New("a cookie is required: provide it with the --cookie_name and --cookie_val flags, or for this publication with --cookies-file")
+	}
+	authenticated, err := extractor.IsAuthenticated(ctx, pageUrl)
+	if err != nil {
+		return err
+	}
+	if !authenticated {
+		return errors.New("the cookie was not recognized: the page was served to an anonymous visitor")
+	}
+	if verbose {
+		fmt.Println("Cookie recognized, proceeding as a logged in reader")
+	}
+	return nil
+}
+
+// makeCommentsPath returns the path of the comments file written next to the post at postPath.
+func makeCommentsPath(postPath string, commentFormat string) string {
+	return fmt.Sprintf("%s.comments.%s", strings.TrimSuffix(postPath, filepath.Ext(postPath)), commentFormat)
+}
+
+// postKey returns the key identifying the post when deduplicating: its id or, if missing, its normalized title.
+// The same post can be listed under different slugs, e.g. after its slug was changed.
+func postKey(post lib.Post) string {
+	if post.Id != 0 {
+		return "id:" + strconv.Itoa(post.Id)
+	}
+	return "title:" + strings.ToLower(strings.Join(strings.Fields(post.Title), " "))
+}
+
+// downloadNote downloads the single note at noteUrl to the output folder, in the chosen format.
+func downloadNote(noteUrl string) error {
+	if verbose {
+		fmt.Printf("Downloading note %s\n", noteUrl)
+	}
+	note, err := extractor.GetNote(ctx, noteUrl)
+	if err != nil {
+		return err
+	}
+	return writeNote(note)
+}
+
+// writeNote writes the note to the output folder, in a file named after its date and id.
+func writeNote(note lib.Note) error {
+	path := fmt.Sprintf("%s/%s_note_c-%d.%s", outputFolder, convertDateTime(note.Date), note.Id, format)
+	if verbose {
+		fmt.Printf("Writing note to file %s\n", path)
+	}
+	return note.WriteToFile(path, format)
+}
+
+// makeFailurePath returns the path of the placeholder file written for the post at postUrl when it fails to download.
+// Its name doesn't match the post files, so it doesn't prevent the post from being downloaded again in the next runs.
+func makeFailurePath(postUrl string) string {
+	return filepath.Join(outputFolder, fileSlug(extractSlug(postUrl))+".failed.txt")
+}
+
+// writeFailure writes the placeholder file for the post at postUrl, which failed to download with err.
+func writeFailure(postUrl string, err error) error {
+	path := makeFailurePath(postUrl)
+	if verbose {
+		fmt.Printf("Writing failure placeholder to file %s\n", path)
+	}
+	content := fmt.Sprintf("url: %s\nerror: %s\n", postUrl, err)
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// makeRawPath returns the path of the raw JSON data file written next to the post at postPath.
+func makeRawPath(postPath string) string {
+	return strings.TrimSuffix(postPath, filepath.Ext(postPath)) + ".raw.json"
+}
+
+// warnPaywalled warns that only the preview of the paywalled post is saved, explaining how to get all of it.
+func warnPaywalled(post lib.Post) {
+	if fetcher.CookieFor(publicationHost(post.CanonicalUrl)) == nil && apiToken == "" {
+		fmt.Printf("Warning: post %s is for paid subscribers: only its preview is saved. Provide the cookie of a subscribed account with --cookie_name and --cookie_val to save all of it\n", post.CanonicalUrl)
+		return
+	}
+	fmt.Printf("Warning: post %s is for paid subscribers: only its preview is saved, since the cookie doesn't grant access to it\n", post.CanonicalUrl)
+}
+
+// makeAudioPath returns the path, without extension, of the audio files downloaded next to the post at postPath.
+func makeAudioPath(postPath string) string {
+	return strings.TrimSuffix(postPath, filepath.Ext(postPath)) + ".audio"
+}
+
+// makeCoverPath returns the path, without extension, of the cover image downloaded next to the post at postPath.
+func makeCoverPath(postPath string) string {
+	return strings.TrimSuffix(postPath, filepath.Ext(postPath)) + ".cover"
+}
+
+// preparePost applies the requested transformations to the post before it is written.
+func preparePost(post *lib.Post) error {
+	if post.IsPaywalled() {
+		warnPaywalled(*post)
+	}
+	if preferReqUrl && post.UseRequestedURL() && verbose {
+		fmt.Printf("Using the requested url %s instead of the canonical one\n", post.CanonicalUrl)
+	}
+	if emailVersion && !post.UseEmailBody() && verbose {
+		fmt.Printf("No email version available for post %s, using the web version\n", post.CanonicalUrl)
+	}
+	// the email version is the body now, or it is not wanted: don't export a second copy of it
+	post.EmailBodyHTML = ""
+	// with both --minimal and --ereader, the e-reader documents are written without images
+	if minimal {
+		body, err := lib.MinimalHTML(post.BodyHTML)
+		if err != nil {
+			return err
+		}
+		post.BodyHTML = body
+	} else if ereader {
+		body, err := lib.EReaderHTML(post.BodyHTML)
+		if err != nil {
+			return err
+		}
+		post.BodyHTML = body
+	}
+	if includeCover && format != "txt" && post.AddCoverImage() && verbose {
+		fmt.Printf("Added the cover image to post %s\n", post.CanonicalUrl)
+	}
+	if sanitize {
+		lib.NewSanitizer().SanitizePost(post)
+	}
+	if stripStyling {
+		body, err := lib.StripStyling(post.BodyHTML)
+		if err != nil {
+			return err
+		}
+		post.BodyHTML = body
+	}
+	if postToc && (format == "html" || format == "md" || format == "epub") {
+		body, err := lib.AddTableOfContents(post.BodyHTML, lib.TOCMinHeadings)
+		if err != nil {
+			return err
+		}
+		post.BodyHTML = body
+	}
+	if selfContained {
+		if err := extractor.EmbedImages(ctx, post); err != nil {
+			return err
+		}
+	}
+	for _, rewrite := range domainRewrites {
+		if err := post.RewriteDomain(rewrite.from, rewrite.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportPageCleanup warns that the page of the post was malformed, and how it was cleaned up to extract the post,
+// e.g. truncated: part of it may be missing.
+func reportPageCleanup(post lib.Post) {
+	if cleanup := post.PageCleanup(); cleanup != "" {
+		fmt.Printf("Warning: the page of post %s is malformed, it was cleaned up to extract the post (%s)\n", post.CanonicalUrl, cleanup)
+	}
+}
+
+// domainRewrite is a domain rewrite of --rewrite-domain, from the domain from to the domain to.
+type domainRewrite struct {
+	from string
+	to   string
+}
+
+// domainRewrites holds the domain rewrites of --rewrite-domain, applied to each post in order.
+var domainRewrites []domainRewrite
+
+// parseDomainRewrites parses the values of --rewrite-domain, in the form old=new.
+func parseDomainRewrites(values []string) ([]domainRewrite, error) {
+	var rewrites []domainRewrite
+	for _, value := range values {
+		from, to, found := strings.Cut(value, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid --rewrite-domain value %q: it must be in the form old=new", value)
+		}
+		rewrites = append(rewrites, domainRewrite{from: from, to: to})
+	}
+	return rewrites, nil
+}
+
+// writePost writes the post to the output folder in the chosen format.
+// If comments are requested, they are appended to the post when their format matches the post one,
+// otherwise they are written to a separate file next to the post.
+func writePost(post lib.Post) error {
+	if err := preparePost(&post); err != nil {
+		return err
+	}
+
+	path := makePath(post, outputFolder, format)
+	if verbose {
+		fmt.Printf("Writing post to file %s\n", path)
+	}
+
+	if saveRaw {
+		if err := post.WriteRawToFile(makeRawPath(path)); err != nil {
+			return err
+		}
+	}
+
+	if downloadAudio {
+		audioPaths, err := extractor.DownloadAudio(ctx, &post, makeAudioPath(path))
+		if err != nil {
+			return err
+		}
+		for _, audioPath := range audioPaths {
+			if verbose {
+				fmt.Printf("Downloaded audio to file %s\n", audioPath)
+			}
+		}
+	}
+
+	if includeCover && format == "txt" {
+		// a txt post cannot show its cover: it is downloaded next to it instead
+		coverPath, err := extractor.DownloadCover(ctx, &post, makeCoverPath(path))
+		if err != nil && !errors.Is(err, lib.ErrNoCover) {
+			return err
+		}
+		if err == nil && verbose {
+			fmt.Printf("Downloaded cover to file %s\n", coverPath)
+		}
+	}
+
+	if validateLinks {
+		if compress == "gzip" {
+			defer checkReferences(path + ".gz")
+		} else {
+			defer checkReferences(path)
+		}
+	}
+
+	opts := writeOptions()
+	if !withComments {
+		return post.WriteToFile(path, format, opts...)
+	}
+
+	comments, ok, err := postComments(post)
+	if err == nil && !ok {
+		return post.WriteToFile(path, format, opts...)
+	}
+	if err != nil {
+		if writeErr := post.WriteToFile(path, format, opts...); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	if commentsFormat() == format {
+		return post.WriteToFileWithComments(path, format, comments, opts...)
+	}
+
+	if err := post.WriteToFile(path, format, opts...); err != nil {
+		return err
+	}
+	return writeCommentsFile(path, comments)
+}
+
+// checkReferences reports the local paths referenced by the written post at path which don't exist on disk.
+func checkReferences(path string) {
+	dangling, err := lib.DanglingReferences(path)
+	if err != nil {
+		if verbose {
+			fmt.Printf("Error validating the links of %s: %s\n", path, err)
+		}
+		return
+	}
+	for _, ref := range dangling {
+		fmt.Printf("Dangling reference in %s: %s\n", path, ref)
+	}
+	danglingCount += len(dangling)
+}
+
+// writeOptions returns the options for writing the posts, based on the command flags.
+func writeOptions() []lib.WriteOption {
+	var opts []lib.WriteOption
+	if fullHTML {
+		opts = append(opts, lib.WithFullHTML())
+	}
+	if baseHref != "" {
+		opts = append(opts, lib.WithBaseHref(baseHref))
+	}
+	if selfContained {
+		opts = append(opts, lib.WithSelfContained())
+	}
+	if ereader {
+		opts = append(opts, lib.WithEReader())
+	}
+	if sitePreset != lib.FrontMatterGeneric {
+		opts = append(opts, lib.WithFrontMatterPreset(sitePreset))
+	} else if frontMatter {
+		opts = append(opts, lib.WithFrontMatter())
+	}
+	if preserveMtime {
+		opts = append(opts, lib.WithPreserveModTime())
+	}
+	if compress == "gzip" {
+		opts = append(opts, lib.WithGzip())
+	}
+	if flatten {
+		opts = append(opts, lib.WithLinkReferences())
+	}
+	if transcript {
+		opts = append(opts, lib.WithTranscript())
+	}
+	if format == "epub" {
+		// the books embed their images, so that they show on the e-readers which don't load remote resources
+		opts = append(opts, lib.WithImages(func(src string, referer string) ([]byte, string, error) {
+			return extractor.FetchImage(ctx, src, referer)
+		}))
+	}
+	return opts
+}
+
+// writeComments writes the comments of the post to a separate file next to it, leaving the post file untouched.
+func writeComments(post lib.Post) error {
+	comments, ok, err := postComments(post)
+	if err != nil || !ok {
+		return err
+	}
+	return writeCommentsFile(makePath(post, outputFolder, format), comments)
+}
+
+// postComments fetches the comments of the post. If they are only accessible to subscribers
+// and --skip-gated-comments is set, ok is false and no error is returned.
+func postComments(post lib.Post) (comments []lib.Comment, ok bool, err error) {
+	comments, err = extractor.GetPostComments(ctx, post)
+	if errors.Is(err, lib.ErrCommentsGated) {
+		if skipGated {
+			if verbose {
+				fmt.Printf("Skipping the comments of post %s: %s\n", post.CanonicalUrl, err)
+			}
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("%w: provide the cookie of a subscribed account with --cookie_name and --cookie_val (or its token with --api-token), or skip them with --skip-gated-comments", err)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if commentsExport != nil {
+		if err := commentsExport.Write(post, comments); err != nil {
+			return nil, false, fmt.Errorf("failed to export comments: %w", err)
+		}
+	}
+	return comments, true, nil
+}
+
+// writeCommentsFile writes the comments to a separate file next to the post at postPath.
+func writeCommentsFile(postPath string, comments []lib.Comment) error {
+	cFormat := commentsFormat()
+	commentsPath := makeCommentsPath(postPath, cFormat)
+	if verbose {
+		fmt.Printf("Writing comments to file %s\n", commentsPath)
+	}
+	return lib.WriteCommentsToFile(commentsPath, comments, cFormat)
+}
+
+// commentsFormat returns the format of the comments, which defaults to the posts one.
+func commentsFormat() string {
+	if commentFormat == "" {
+		return format
+	}
+	return commentFormat
+}
+
+// writePublication writes the metadata of the publication at pubUrl to publication.json in the output folder,
+// and returns it.
+func writePublication(pubUrl string, postCount int) (lib.Publication, error) {
+	pub, err := extractor.ExtractPublication(ctx, pubUrl)
+	if err != nil {
+		return pub, err
+	}
+	pub.PostCount = postCount
+
+	path := filepath.Join(outputFolder, "publication.json")
+	if verbose {
+		fmt.Printf("Writing publication metadata to file %s\n", path)
+	}
+	return pub, pub.WriteToFile(path)
+}
+
+// makeBookPath returns the path of the book written with --epub-book, named after the host of the publication at pubUrl.
+func makeBookPath(pubUrl string) string {
+	return filepath.Join(outputFolder, fileSlug(publicationHost(pubUrl))+".epub")
 }
 
-// extractSlug extracts the slug from a Substack post URL
-// e.g. https://example.substack.com/p/this-is-the-post-title -> this-is-the-post-title
-func extractSlug(url string) string {
-	split := strings.Split(url, "/")
-	return split[len(split)-1]
+// publicationHost returns the host name of the publication at pubUrl, e.g. example.substack.com.
+func publicationHost(pubUrl string) string {
+	u, err := url.Parse(pubUrl)
+	if err != nil || u.Hostname() == "" {
+		return pubUrl
+	}
+	return u.Hostname()
+}
+
+// downloadCover downloads the cover image of the post to the covers folder, named after its date and slug,
+// and adds it to the covers listed in the gallery. The posts without a cover are skipped.
+func downloadCover(post lib.Post) error {
+	basePath := filepath.Join(outputFolder, coversFolder, fmt.Sprintf("%s_%s", convertDateTime(post.PostDate), fileSlug(post.Slug)))
+	path, err := extractor.DownloadCover(ctx, &post, basePath)
+	if errors.Is(err, lib.ErrNoCover) {
+		if verbose {
+			fmt.Printf("Post %s has no cover image, skipping...\n", post.CanonicalUrl)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Downloaded cover to file %s\n", path)
+	}
+	covers = append(covers, lib.Cover{
+		Title:   post.Title,
+		PostUrl: post.CanonicalUrl,
+		Date:    convertDate(post.PostDate),
+		Image:   url.PathEscape(filepath.Base(path)),
+	})
+	return nil
+}
+
+// exportPost appends the post to the JSON Lines file of --jsonl-output.
+func exportPost(post lib.Post) error {
+	if err := preparePost(&post); err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Writing post to file %s\n", jsonlPath)
+	}
+	return postsExport.Write(post)
+}
+
+// addToBook returns a function adding the posts, with their comments if requested, as chapters of the book.
+func addToBook(book *lib.EPUBBook) func(lib.Post) error {
+	return func(post lib.Post) error {
+		if err := preparePost(&post); err != nil {
+			return err
+		}
+		opts := writeOptions()
+		if withComments {
+			comments, ok, err := postComments(post)
+			if err != nil {
+				return err
+			}
+			if ok {
+				opts = append(opts, lib.WithComments(comments))
+			}
+		}
+		return book.AddPost(&post, opts...)
+	}
+}
+
+// extractSlug extracts the slug from a Substack post URL, ignoring any trailing slash, query string, and fragment
+// e.g. https://example.substack.com/p/this-is-the-post-title/?utm_source=x#comments -> this-is-the-post-title
+func extractSlug(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		// not a valid URL: fall back to cutting the query and fragment by hand
+		rawUrl, _, _ = strings.Cut(rawUrl, "#")
+		rawUrl, _, _ = strings.Cut(rawUrl, "?")
+		u = &url.URL{Path: rawUrl}
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
 }
 
 // filterExistingPosts filters out posts that already exist in the output folder.
@@ -187,15 +1199,57 @@ func extractSlug(url string) string {
 func filterExistingPosts(urls []string, outputFolder string, format string) ([]string, error) {
 	var filtered []string
 	for _, url := range urls {
-		slug := extractSlug(url)
-		path := fmt.Sprintf("%s/%s_%s.%s", outputFolder, "*", slug, format)
-		matches, err := filepath.Glob(path)
+		exists, err := postExists(url, outputFolder, format)
 		if err != nil {
 			return urls, err
 		}
-		if len(matches) == 0 {
+		if !exists {
 			filtered = append(filtered, url)
 		}
 	}
 	return filtered, nil
 }
+
+// filterMissingPosts filters out posts that don't exist in the output folder yet.
+func filterMissingPosts(urls []string, outputFolder string, format string) ([]string, error) {
+	var filtered []string
+	for _, url := range urls {
+		exists, err := postExists(url, outputFolder, format)
+		if err != nil {
+			return urls, err
+		}
+		if exists {
+			filtered = append(filtered, url)
+		}
+	}
+	return filtered, nil
+}
+
+// postExists reports whether the post at url has already been downloaded in the output folder.
+// It looks for files laid out with --output-template for the post slug, whatever their date, title and id.
+func postExists(url string, outputFolder string, format string) (bool, error) {
+	slug := fileSlug(extractSlug(url))
+	pattern := outputTemplateGlob(outputTmpl, slug, format)
+	if pattern == "" {
+		// the files cannot be matched without their slug: the post is downloaded again
+		return false, nil
+	}
+	path := fmt.Sprintf("%s/%s", outputFolder, pattern)
+	switch sitePreset {
+	case lib.FrontMatterHugo:
+		path = filepath.Join(outputFolder, "content", "posts", slug+".md")
+	case lib.FrontMatterJekyll:
+		path = filepath.Join(outputFolder, "_posts", "*-"+slug+".md")
+	}
+	// the post can have been downloaded compressed or not
+	for _, pattern := range []string{path, path + ".gz"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(matches) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
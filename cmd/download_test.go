@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractSlug(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.substack.com/p/test-post", "test-post"},
+		{"https://example.substack.com/p/test-post?utm_source=newsletter", "test-post"},
+		{"https://example.substack.com/p/test-post#comments", "test-post"},
+		{"https://example.substack.com/p/test-post/?utm_source=x#comments", "test-post"},
+		{"test-post?utm_source=newsletter", "test-post"},
+		{"%zz/test-post#comments", "test-post"},
+	}
+	for _, tt := range tests {
+		if got := extractSlug(tt.url); got != tt.want {
+			t.Errorf("extractSlug(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseDomainRewrites(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []domainRewrite
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"example.substack.com=example.com"}, []domainRewrite{{"example.substack.com", "example.com"}}, false},
+		{[]string{"a.substack.com=a.com", "https://b.substack.com=https://b.com"}, []domainRewrite{{"a.substack.com", "a.com"}, {"https://b.substack.com", "https://b.com"}}, false},
+		{[]string{"example.substack.com"}, nil, true},
+		{[]string{"example.substack.com="}, nil, true},
+		{[]string{"=example.com"}, nil, true},
+		{[]string{"a.substack.com=a.com", " = "}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDomainRewrites(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDomainRewrites(%q) error = %v, want error: %v", tt.values, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDomainRewrites(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
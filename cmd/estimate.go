@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+// archiveEstimate holds the averages measured over a sample of posts.
+type archiveEstimate struct {
+	sampled     int
+	postBytes   int64
+	assets      int
+	assetBytes  int64
+	unsizedURLs int
+}
+
+// estimateArchive downloads a sample of the posts at urls and measures the size of the posts
+// and of the assets (images and files) they reference, without downloading the assets.
+// It then prints an estimate of the size and number of requests for the whole archive.
+func estimateArchive(urls []string, sampleSize int) error {
+	if sampleSize <= 0 || sampleSize > len(urls) {
+		sampleSize = len(urls)
+	}
+
+	// pick the sample evenly across the archive, as older and newer posts can differ a lot
+	var est archiveEstimate
+	step := float64(len(urls)) / float64(sampleSize)
+	for i := 0; i < sampleSize; i++ {
+		u := urls[int(float64(i)*step)]
+		if verbose {
+			fmt.Printf("Sampling post %s\n", u)
+		}
+		post, err := extractor.ExtractPost(ctx, u)
+		if err != nil {
+			if verbose {
+				fmt.Printf("Error sampling post %s: %s\n", u, err)
+			}
+			continue
+		}
+		est.sampled++
+		est.postBytes += int64(len(post.BodyHTML))
+
+		assetURLs, err := post.AssetURLs()
+		if err != nil {
+			continue
+		}
+		for _, assetURL := range assetURLs {
+			est.assets++
+			size, err := fetcher.FetchContentLength(ctx, assetURL)
+			if err != nil || size < 0 {
+				est.unsizedURLs++
+				continue
+			}
+			est.assetBytes += size
+		}
+	}
+
+	if est.sampled == 0 {
+		return fmt.Errorf("none of the %d sampled posts could be downloaded", sampleSize)
+	}
+
+	postsCount := int64(len(urls))
+	avgPostBytes := est.postBytes / int64(est.sampled)
+	avgAssets := float64(est.assets) / float64(est.sampled)
+	var avgAssetBytes int64
+	if sized := est.assets - est.unsizedURLs; sized > 0 {
+		avgAssetBytes = est.assetBytes / int64(sized)
+	}
+	totalAssets := int64(avgAssets * float64(postsCount))
+
+	fmt.Printf("Estimate based on %d sampled posts, out of %d:\n", est.sampled, postsCount)
+	fmt.Printf("  posts:  %d, about %s\n", postsCount, formatBytes(avgPostBytes*postsCount))
+	fmt.Printf("  assets: about %d images and files (%.1f per post), about %s\n", totalAssets, avgAssets, formatBytes(avgAssetBytes*totalAssets))
+	fmt.Printf("  requests: about %d for the posts, %d more to download the assets\n", postsCount, totalAssets)
+	if est.unsizedURLs > 0 {
+		fmt.Printf("  %d sampled assets didn't report their size and are not counted in the size estimate\n", est.unsizedURLs)
+	}
+	return nil
+}
+
+// formatBytes returns a human readable representation of the size b.
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
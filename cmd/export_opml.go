@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+	"github.com/spf13/cobra"
+)
+
+// exportOPMLCmd represents the export-opml command
+var (
+	opmlOutput    string
+	exportOPMLCmd = &cobra.Command{
+		Use:   "export-opml",
+		Short: "Export the publications you are subscribed to as an OPML file",
+		Long:  `Export the RSS feeds of the publications you are subscribed to as an OPML file, which can be imported in any RSS reader. It requires the cookie of your session.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if fetcher.Cookie == nil && fetcher.APIToken == "" {
+				log.Fatalln("export-opml requires the --cookie_name and --cookie_val flags, or the --api-token flag")
+			}
+			if verbose {
+				fmt.Println("Getting subscriptions...")
+			}
+			pubs, err := extractor.GetSubscriptions(ctx)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if verbose {
+				fmt.Printf("Found %d subscriptions.\n", len(pubs))
+			}
+			if err := lib.WriteOPML(opmlOutput, "Substack subscriptions", pubs); err != nil {
+				log.Fatalln(err)
+			}
+			if verbose {
+				fmt.Printf("Subscriptions written to %s\n", opmlOutput)
+			}
+		},
+	}
+)
+
+func init() {
+	exportOPMLCmd.Flags().StringVarP(&opmlOutput, "output", "o", "subscriptions.opml", "Specify the OPML file to write")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+)
+
+// asciiFolds maps the non-ASCII letters most common in slugs to their closest ASCII spelling.
+var asciiFolds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// asciiFilename folds name to ASCII for --ascii-filenames: accented letters lose their accents (é -> e),
+// and any other non-ASCII character, e.g. an emoji, is replaced by a dash. Repeated dashes are collapsed.
+func asciiFilename(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if r <= unicode.MaxASCII {
+			sb.WriteRune(r)
+			continue
+		}
+		lower := unicode.ToLower(r)
+		fold, ok := asciiFolds[lower]
+		if !ok {
+			sb.WriteString("-")
+			continue
+		}
+		if lower != r {
+			fold = strings.ToUpper(fold[:1]) + fold[1:]
+		}
+		sb.WriteString(fold)
+	}
+	folded := sb.String()
+	for strings.Contains(folded, "--") {
+		folded = strings.ReplaceAll(folded, "--", "-")
+	}
+	folded = strings.Trim(folded, "-")
+	if folded == "" {
+		return "post"
+	}
+	return folded
+}
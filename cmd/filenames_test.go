@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+func TestASCIIFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"plain-slug", "plain-slug"},
+		{"café-crème", "cafe-creme"},
+		{"Ångström-Ærø", "Angstrom-Aero"},
+		{"straße-łódź", "strasse-lodz"},
+		{"hello-🎉-world", "hello-world"},
+		{"🚀launch🚀", "launch"},
+		{"日本語", "post"},
+		{"naïve-😀😀-idea", "naive-idea"},
+	}
+	for _, tt := range tests {
+		if got := asciiFilename(tt.name); got != tt.want {
+			t.Errorf("asciiFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMakePathASCIIFilenames(t *testing.T) {
+	defer func() { asciiNames = false }()
+	post := lib.Post{Slug: "café-🎉", PostDate: "2024-03-15T10:00:00Z"}
+	tests := []struct {
+		ascii bool
+		want  string
+	}{
+		{false, "out/20240315_100000_café-🎉.html"},
+		{true, "out/20240315_100000_cafe.html"},
+	}
+	for _, tt := range tests {
+		asciiNames = tt.ascii
+		if got := filepath.ToSlash(makePath(post, "out", "html")); got != tt.want {
+			t.Errorf("makePath with --ascii-filenames=%v = %q, want %q", tt.ascii, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// indexDataFile is the file, in the output folder, storing the posts listed by the archive index across runs.
+const indexDataFile = "index.json"
+
+// archiveIndex lists the posts downloaded with --index, if not nil
+var archiveIndex *lib.ArchiveIndex
+
+// loadArchiveIndex loads the index of the posts downloaded by the previous runs from the output folder,
+// to add the posts of this run to it. The title is kept from the previous runs unless given.
+func loadArchiveIndex(title string) error {
+	idx, err := lib.ReadArchiveIndex(filepath.Join(outputFolder, indexDataFile))
+	if err != nil {
+		return err
+	}
+	if title != "" {
+		idx.Title = title
+	}
+	archiveIndex = idx
+	return nil
+}
+
+// indexPost lists the written post in the archive index, with the path of its file relative to the output folder.
+func indexPost(post lib.Post) {
+	if archiveIndex == nil {
+		return
+	}
+	// the post is written after the url it was requested from: link to that file
+	if preferReqUrl {
+		post.UseRequestedURL()
+	}
+	path := makePath(post, outputFolder, format)
+	if compress == "gzip" {
+		path += ".gz"
+	}
+	relPath, err := filepath.Rel(outputFolder, path)
+	if err != nil {
+		relPath = filepath.Base(path)
+	}
+	archiveIndex.Add(lib.IndexEntry{
+		Title: post.Title,
+		Date:  post.PostDate,
+		Url:   post.CanonicalUrl,
+		Path:  filepath.ToSlash(relPath),
+	})
+}
+
+// writeArchiveIndex writes the archive index to index.html in the output folder, and to index.md with --index-md,
+// along with the data read back by the next runs. The posts whose file was removed since they were listed are left out.
+func writeArchiveIndex() {
+	if archiveIndex == nil {
+		return
+	}
+	for _, e := range archiveIndex.RemoveMissing(outputFolder) {
+		if verbose {
+			fmt.Printf("Removing post %s from the index: its file %s is gone\n", e.Url, e.Path)
+		}
+	}
+	if archiveIndex.Title == "" {
+		archiveIndex.Title = "Archive"
+	}
+	path := filepath.Join(outputFolder, "index.html")
+	if verbose {
+		fmt.Printf("Writing the index of %d posts to file %s\n", len(archiveIndex.Entries), path)
+	}
+	if err := archiveIndex.WriteJSON(filepath.Join(outputFolder, indexDataFile)); err != nil {
+		fmt.Println("Error writing the index:", err)
+		return
+	}
+	if err := archiveIndex.WriteHTML(path); err != nil {
+		fmt.Println("Error writing the index:", err)
+	}
+	if indexMarkdown {
+		if err := archiveIndex.WriteMarkdown(filepath.Join(outputFolder, "index.md")); err != nil {
+			fmt.Println("Error writing the index:", err)
+		}
+	}
+}
@@ -1,25 +1,36 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/alexferrari88/sbstck-dl/lib"
 	"github.com/spf13/cobra"
 )
 
 // listCmd represents the list command
 var (
-	pubUrl  string
-	listCmd = &cobra.Command{
+	pubUrl     string
+	listOutput string
+	listCmd    = &cobra.Command{
 		Use:   "list",
 		Short: "List the posts of a Substack",
 		Long:  `List the posts of a Substack`,
 		Run: func(cmd *cobra.Command, args []string) {
-			parsedURL, err := parseURL(pubUrl)
+			switch listOutput {
+			case "urls", "json", "csv", "table":
+			default:
+				log.Fatalf("unknown output format: %s", listOutput)
+			}
+			mainWebsite, err := publicationRoot(pubUrl)
 			if err != nil {
 				log.Fatal(err)
 			}
-			mainWebsite := fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host)
 			if verbose {
 				fmt.Printf("Main website: %s\n", mainWebsite)
 				fmt.Println("Getting all posts URLs...")
@@ -32,8 +43,25 @@ var (
 			if verbose {
 				fmt.Printf("Found %d posts.\n", len(urls))
 			}
-			for _, url := range urls {
-				fmt.Println(url)
+			if len(tags) > 0 {
+				// the sitemap doesn't list the tags: every post has to be fetched to know them
+				urls = filterTaggedPosts(urls)
+			}
+			if listOutput == "urls" {
+				for _, url := range urls {
+					fmt.Println(url)
+				}
+				return
+			}
+			if verbose {
+				fmt.Println("Getting the metadata of the posts...")
+			}
+			summaries, err := extractor.GetPostSummaries(ctx, mainWebsite, urls)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := printPostSummaries(listOutput, summaries); err != nil {
+				log.Fatal(err)
 			}
 		},
 	}
@@ -41,5 +69,55 @@ var (
 
 func init() {
 	listCmd.Flags().StringVarP(&pubUrl, "url", "u", "", "Specify the Substack url")
+	listCmd.Flags().StringVar(&listOutput, "output-format", "urls", "Specify the output format (options: \"urls\", one url per line, or \"json\", \"csv\", \"table\", listing the title, date, slug and url of each post, read from the archive API or from the posts it misses)")
 	listCmd.MarkFlagRequired("url")
 }
+
+// printPostSummaries prints the metadata of the posts in the format chosen with --output-format.
+func printPostSummaries(outputFormat string, summaries []lib.PostSummary) error {
+	switch outputFormat {
+	case "json":
+		b, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"title", "date", "slug", "url"})
+		for _, s := range summaries {
+			w.Write([]string{s.Title, s.Date, s.Slug, s.Url})
+		}
+		w.Flush()
+		return w.Error()
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "DATE\tTITLE\tSLUG\tURL")
+		for _, s := range summaries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", convertDate(s.Date), strings.Join(strings.Fields(s.Title), " "), s.Slug, s.Url)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// filterTaggedPosts returns the urls of the posts with any of the tags, in the same order.
+// The posts which fail to download are left out.
+func filterTaggedPosts(urls []string) []string {
+	var tagged []string
+	for result := range extractor.ExtractAllPostsOrdered(ctx, urls) {
+		if result.Err != nil {
+			if verbose {
+				fmt.Printf("Error downloading post %s: %s\n", result.Url, result.Err)
+			}
+			continue
+		}
+		if result.Post.HasTag(tags...) {
+			tagged = append(tagged, result.Url)
+		}
+	}
+	if verbose {
+		fmt.Printf("Found %d posts with the tags %s.\n", len(tagged), strings.Join(tags, ", "))
+	}
+	return tagged
+}
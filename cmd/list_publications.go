@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// listPublicationsCmd represents the list-publications command
+var (
+	profileUser         string
+	listPublicationsCmd = &cobra.Command{
+		Use:   "list-publications",
+		Short: "List the publications of a Substack user",
+		Long:  `List the publications a Substack user writes for or subscribes to, from their public profile, to help finding the url of a publication.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if verbose {
+				fmt.Printf("Getting the publications of %s...\n", profileUser)
+			}
+			pubs, err := extractor.GetProfilePublications(ctx, profileUser)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if verbose {
+				fmt.Printf("Found %d publications written and %d subscriptions.\n", len(pubs.Writes), len(pubs.Subscriptions))
+			}
+			for _, p := range pubs.Writes {
+				fmt.Printf("writes\t%s\t%s\n", p.Name, p.URL())
+			}
+			for _, p := range pubs.Subscriptions {
+				fmt.Printf("subscribes\t%s\t%s\n", p.Name, p.URL())
+			}
+		},
+	}
+)
+
+func init() {
+	listPublicationsCmd.Flags().StringVarP(&profileUser, "user", "u", "", "Specify the handle or the profile url of the user (e.g. @handle or https://substack.com/@handle)")
+	listPublicationsCmd.MarkFlagRequired("user")
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNotesSince(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		last    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", "2024-03-01T10:00:00Z", time.Time{}, false},
+		{"2024-01-31", "", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), false},
+		{"7d", "", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC), false},
+		{"last", "2024-03-01T10:00:00Z", time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), false},
+		{"last", "", time.Time{}, false},
+		{"last", "yesterday", time.Time{}, true},
+		{"01/31/2024", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseNotesSince(tt.value, tt.last, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseNotesSince(%q, %q) error = %v, want error: %v", tt.value, tt.last, err, tt.wantErr)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseNotesSince(%q, %q) = %s, want %s", tt.value, tt.last, got, tt.want)
+		}
+	}
+}
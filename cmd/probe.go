@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// probeCmd represents the probe command
+var (
+	probeUrl    string
+	probeOutput string
+	probeCmd    = &cobra.Command{
+		Use:   "probe",
+		Short: "Check whether a website is a Substack and which access methods work",
+		Long:  `Check whether a website, e.g. a custom domain, is a Substack publication, and which of the methods used to download it work: the page data, the API, the sitemap, and the RSS feed. Each check is reported as passed or failed, and the command exits with status 1 if any of them failed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if probeOutput != "text" && probeOutput != "json" {
+				log.Fatalf("unknown output format: %s", probeOutput)
+			}
+			mainWebsite, err := publicationRoot(probeUrl)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if verbose {
+				fmt.Printf("Probing %s...\n", mainWebsite)
+			}
+			result, err := extractor.Probe(ctx, mainWebsite)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if probeOutput == "text" {
+				if result.IsSubstack {
+					fmt.Printf("%s is a Substack publication", result.Url)
+					if result.Name != "" {
+						fmt.Printf(" (%s)", result.Name)
+					}
+					fmt.Println()
+				} else {
+					fmt.Printf("%s is not a Substack publication\n", result.Url)
+				}
+			}
+			reportChecks(probeOutput, result, result.Checks)
+		},
+	}
+)
+
+func init() {
+	probeCmd.Flags().StringVarP(&probeUrl, "url", "u", "", "Specify the url of the website")
+	probeCmd.Flags().StringVar(&probeOutput, "output", "text", "Specify the output format (options: \"text\", \"json\")")
+	probeCmd.MarkFlagRequired("url")
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// trashFolder is the folder, in the output folder, where --prune moves the stale posts.
+const trashFolder = ".trash"
+
+var (
+	// postFileRegex matches the name of the post files, YYYYMMDD_HHMMSS_<slug>.<format>, capturing the slug.
+	// The date is empty when the post had none.
+	postFileRegex = regexp.MustCompile(`^(?:\d{8}_\d{6})?_(.+)$`)
+	// jekyllFileRegex matches the name of the Jekyll posts, YYYY-MM-DD-<slug>.md, capturing the slug.
+	jekyllFileRegex = regexp.MustCompile(`^(?:\d{4}-\d{2}-\d{2})?-(.+)$`)
+	// noteSlugRegex matches the slug part of the note files, which are not posts.
+	noteSlugRegex = regexp.MustCompile(`^note_c-\d+$`)
+	// companionSlugRegex matches the slug part of the files written next to the posts, e.g. <post>.comments.<format>,
+	// which are not posts. The slugs of the posts have no dots.
+	companionSlugRegex = regexp.MustCompile(`\.(?:comments|raw|cover|audio(?:-\d+)?|failed)$`)
+)
+
+// pruneStalePosts moves the local posts which are no longer in the archive, listed at urls, to the trash folder,
+// along with their comments, cover, audio and raw data files, and reports each of them.
+// Nothing is pruned once the run is interrupted, e.g. by --max-duration: the listing may be partial.
+func pruneStalePosts(urls []string) error {
+	if ctx.Err() != nil {
+		fmt.Println("Skipping the prune: the listing of the archive was interrupted, it may be incomplete")
+		return nil
+	}
+	listed := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		listed[fileSlug(extractSlug(u))] = true
+	}
+
+	local, err := localPosts()
+	if err != nil {
+		return err
+	}
+
+	var prunedCount int
+	for path, slug := range local {
+		if listed[slug] {
+			continue
+		}
+		if err := trashPost(path); err != nil {
+			return err
+		}
+		fmt.Printf("Pruned post %s: it is no longer in the archive\n", path)
+		prunedCount++
+	}
+	if prunedCount > 0 {
+		fmt.Println("Moved", prunedCount, "stale posts to", filepath.Join(outputFolder, trashFolder))
+	}
+	return nil
+}
+
+// localPosts returns the post files in the output folder, compressed or not, along with their slug.
+// It follows the same layout as makePath.
+func localPosts() (map[string]string, error) {
+	dir := outputFolder
+	ext := "." + format
+	nameRegex := postFileRegex
+	switch sitePreset {
+	case lib.FrontMatterHugo:
+		dir, nameRegex = filepath.Join(outputFolder, "content", "posts"), nil
+	case lib.FrontMatterJekyll:
+		dir, nameRegex = filepath.Join(outputFolder, "_posts"), jekyllFileRegex
+	default:
+		if outputTmpl != defaultOutputTemplate {
+			return templatePosts()
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".gz")
+		if !strings.HasSuffix(name, ext) {
+			continue
+		}
+		slug := strings.TrimSuffix(name, ext)
+		if nameRegex != nil {
+			match := nameRegex.FindStringSubmatch(slug)
+			if match == nil {
+				continue
+			}
+			slug = match[1]
+		}
+		if noteSlugRegex.MatchString(slug) || companionSlugRegex.MatchString(slug) {
+			continue
+		}
+		posts[filepath.Join(dir, entry.Name())] = slug
+	}
+	return posts, nil
+}
+
+// templatePosts returns the post files laid out with a custom --output-template, compressed or not, along with their slug.
+func templatePosts() (map[string]string, error) {
+	pathRegex := outputTemplateRegex(outputTmpl, format)
+	posts := make(map[string]string)
+	err := filepath.WalkDir(outputFolder, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name := entry.Name(); path != outputFolder && (name == trashFolder || name == coversFolder) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(outputFolder, path)
+		if err != nil {
+			return err
+		}
+		if rel == "index.html" || rel == "index.md" {
+			// the archive index of --index
+			return nil
+		}
+		match := pathRegex.FindStringSubmatch(strings.TrimSuffix(filepath.ToSlash(rel), ".gz"))
+		if match == nil || noteSlugRegex.MatchString(match[1]) || companionSlugRegex.MatchString(match[1]) {
+			return nil
+		}
+		posts[path] = match[1]
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return posts, err
+}
+
+// trashPost moves the post file at path, and the comments, cover, audio and raw data files next to it,
+// to the trash folder, keeping their path relative to the output folder.
+func trashPost(path string) error {
+	postPath := strings.TrimSuffix(path, ".gz")
+	companions, err := filepath.Glob(makeCommentsPath(postPath, "*"))
+	if err != nil {
+		return err
+	}
+	covers, err := filepath.Glob(makeCoverPath(postPath) + ".*")
+	if err != nil {
+		return err
+	}
+	companions = append(companions, covers...)
+	audio, err := filepath.Glob(makeAudioPath(postPath) + "*")
+	if err != nil {
+		return err
+	}
+	companions = append(companions, audio...)
+	companions = append(companions, makeRawPath(postPath))
+
+	for _, p := range append([]string{path}, companions...) {
+		rel, err := filepath.Rel(outputFolder, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(outputFolder, trashFolder, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(p, dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
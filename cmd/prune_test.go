@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPruneStalePostsInterrupted(t *testing.T) {
+	defer func(c context.Context, dir string) { ctx, outputFolder = c, dir }(ctx, outputFolder)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantPruned bool
+	}{
+		{"complete listing", context.Background(), true},
+		{"interrupted listing", cancelled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, outputFolder = tt.ctx, t.TempDir()
+			listed := filepath.Join(outputFolder, "20240101_100000_listed."+format)
+			missing := filepath.Join(outputFolder, "20240201_100000_missing."+format)
+			for _, path := range []string{listed, missing} {
+				if err := os.WriteFile(path, []byte("post"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the partial listing only has the first post
+			if err := pruneStalePosts([]string{"https://example.substack.com/p/listed"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(listed); err != nil {
+				t.Errorf("the listed post was pruned: %s", err)
+			}
+			_, err := os.Stat(missing)
+			if pruned := os.IsNotExist(err); pruned != tt.wantPruned {
+				t.Errorf("missing post pruned = %v, want %v", pruned, tt.wantPruned)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// readwise saves the downloaded posts to Readwise Reader with --export-readwise, if not nil
+var readwise *lib.ReadwiseClient
+
+// withReadwise returns write, also saving each written post to Readwise Reader with --export-readwise.
+func withReadwise(write func(lib.Post) error) func(lib.Post) error {
+	if readwise == nil {
+		return write
+	}
+	return func(post lib.Post) error {
+		if err := write(post); err != nil {
+			return err
+		}
+		if sanitize {
+			lib.NewSanitizer().SanitizePost(&post)
+		}
+		created, err := readwise.Save(ctx, post.ReadwiseDocument())
+		if err != nil {
+			return fmt.Errorf("error saving post %s to Readwise: %w", post.CanonicalUrl, err)
+		}
+		if verbose {
+			if created {
+				fmt.Printf("Saved post %s to Readwise\n", post.CanonicalUrl)
+			} else {
+				fmt.Printf("Post %s is already in Readwise\n", post.CanonicalUrl)
+			}
+		}
+		return nil
+	}
+}
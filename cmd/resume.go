@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// resumeStateFile is the file, in the output folder, recording the posts processed by the runs with --max-posts-per-run,
+// so that each run continues with the next ones, whatever the output, and the newest note downloaded by the notes command.
+const resumeStateFile = ".sbstck-dl-state.json"
+
+// resumeState records the posts processed by the previous runs with --max-posts-per-run.
+type resumeState struct {
+	// Processed holds the urls of the posts processed by the previous runs, by output mode (see resumeMode),
+	// e.g. the posts added to a book are still to be downloaded as files.
+	Processed map[string][]string `json:"processed,omitempty"`
+
+	// LastNotes holds the date of the newest note downloaded by the notes command, by user handle,
+	// for --notes-since last.
+	LastNotes map[string]string `json:"last_notes,omitempty"`
+
+	// processed holds the urls of the posts processed in the current mode, including by this run
+	processed map[string]bool
+}
+
+// resume is the state of --max-posts-per-run, if not nil
+var resume *resumeState
+
+// resumeMode returns the output mode the processed posts are recorded for.
+func resumeMode() string {
+	switch {
+	case commentsOnly:
+		return "comments"
+	case coverOnly:
+		return "covers"
+	case jsonlPath != "":
+		return "jsonl"
+	case epubBook:
+		return "epub-book"
+	}
+	return "posts"
+}
+
+// loadResumeState loads the state of the previous runs from the output folder, if any.
+func loadResumeState() error {
+	state := &resumeState{Processed: make(map[string][]string), LastNotes: make(map[string]string)}
+	b, err := os.ReadFile(filepath.Join(outputFolder, resumeStateFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, state); err != nil {
+			return fmt.Errorf("invalid state file %s: %w", resumeStateFile, err)
+		}
+		if state.Processed == nil {
+			state.Processed = make(map[string][]string)
+		}
+		if state.LastNotes == nil {
+			state.LastNotes = make(map[string]string)
+		}
+	}
+	state.processed = make(map[string]bool)
+	for _, u := range state.Processed[resumeMode()] {
+		state.processed[u] = true
+	}
+	resume = state
+	return nil
+}
+
+// filterProcessedPosts returns the urls of the posts not processed yet by the previous runs, in the same order.
+func filterProcessedPosts(urls []string) []string {
+	var filtered []string
+	for _, u := range urls {
+		if !resume.processed[u] {
+			filtered = append(filtered, u)
+		}
+	}
+	return filtered
+}
+
+// markProcessed records the post at postUrl as processed, so that the next runs skip it.
+func markProcessed(postUrl string) {
+	if resume == nil || resume.processed[postUrl] {
+		return
+	}
+	resume.processed[postUrl] = true
+	mode := resumeMode()
+	resume.Processed[mode] = append(resume.Processed[mode], postUrl)
+}
+
+// saveResumeState writes the state to the output folder, for the next runs.
+func saveResumeState() {
+	if resume == nil {
+		return
+	}
+	b, err := json.MarshalIndent(resume, "", "  ")
+	if err == nil {
+		// an interrupted save leaves the previous state, not a corrupt one
+		err = lib.WriteFile(filepath.Join(outputFolder, resumeStateFile), b)
+	}
+	if err != nil {
+		fmt.Println("Error writing the state of the run:", err)
+	}
+}
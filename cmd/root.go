@@ -3,10 +3,19 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alexferrari88/sbstck-dl/lib"
 	"github.com/spf13/cobra"
@@ -40,13 +49,32 @@ func (c *cookieName) Type() string {
 }
 
 var (
-	proxyURL       string
-	verbose        bool
-	ratePerSecond  int
-	beforeDate     string
-	afterDate      string
+	proxyURL      string
+	verbose       bool
+	ratePerSecond int
+	adaptiveRate  bool
+	retryStatus   []int
+	workers       int
+	workersAuto   bool
+	maxRequests   int64
+	insecureTLS   bool
+	apiToken      string
+	listingTTL    time.Duration
+	useAPI        bool
+	minDelay      time.Duration
+	userAgent     string
+	noCache       bool
+	httpCacheDir  string
+	beforeDate    string
+	afterDate     string
+	tags          []string
+	maxDuration   time.Duration
+	// stopDeadline releases the timer of --max-duration
+	stopDeadline   context.CancelFunc
 	idCookieName   cookieName
 	idCookieVal    string
+	cookiesFile    string
+	cookieFile     string
 	ctx            = context.Background()
 	parsedProxyURL *url.URL
 	fetcher        *lib.Fetcher
@@ -86,16 +114,102 @@ var (
 				}
 			}
 
-			fetcher = lib.NewFetcher(lib.WithRatePerSecond(ratePerSecond), lib.WithProxyURL(parsedProxyURL), lib.WithCookie(cookie))
+			if cookieFile != "" {
+				if idCookieVal != "" {
+					log.Fatal("--cookie-file and --cookie_val both give the cookie: use only one of them")
+				}
+				var err error
+				cookie, err = loadCookieFile(cookieFile)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			fetcherOpts := []lib.FetcherOption{lib.WithRatePerSecond(ratePerSecond), lib.WithProxyURL(parsedProxyURL), lib.WithCookie(cookie)}
+			if urlFlag := cmd.Flags().Lookup("url"); urlFlag != nil {
+				// the cookie is only sent to the publication, e.g. on its custom domain, and to Substack, not to the CDNs
+				if u, err := url.Parse(urlFlag.Value.String()); err == nil && u.Hostname() != "" {
+					fetcherOpts = append(fetcherOpts, lib.WithCookieHosts(u.Hostname()))
+				}
+			}
+			if adaptiveRate {
+				fetcherOpts = append(fetcherOpts, lib.WithAdaptiveRate())
+			}
+			if cookiesFile != "" {
+				hostCookies, err := loadCookiesFile(cookiesFile)
+				if err != nil {
+					log.Fatal(err)
+				}
+				fetcherOpts = append(fetcherOpts, lib.WithHostCookies(hostCookies))
+			}
+			if apiToken != "" {
+				fetcherOpts = append(fetcherOpts, lib.WithAPIToken(apiToken))
+			}
+			if insecureTLS {
+				fmt.Fprintln(os.Stderr, "WARNING: TLS certificate verification is disabled, the connections can be intercepted. Use --insecure-skip-tls-verify only for websites with a broken certificate.")
+				fetcherOpts = append(fetcherOpts, lib.WithInsecureSkipVerify())
+			}
+			fetcherOpts = append(fetcherOpts, lib.WithUserAgent(userAgent))
+			if minDelay > 0 {
+				fetcherOpts = append(fetcherOpts, lib.WithMinDelay(minDelay))
+			}
+			if httpCacheDir != "" {
+				fetcherOpts = append(fetcherOpts, lib.WithCache(httpCacheDir))
+			}
+			if maxRequests > 0 {
+				fetcherOpts = append(fetcherOpts, lib.WithMaxRequests(maxRequests))
+			}
+			if cmd.Flags().Changed("retry-status") {
+				fetcherOpts = append(fetcherOpts, lib.WithRetryableStatusCodes(retryStatus...))
+			}
+
+			if workersAuto {
+				workers = autoWorkers(ratePerSecond)
+				if verbose {
+					fmt.Printf("Using %d workers\n", workers)
+				}
+			}
+
+			if maxDuration > 0 {
+				// the commands stop as on an interrupt once elapsed, keeping what they completed
+				ctx, stopDeadline = context.WithTimeout(ctx, maxDuration)
+			}
+
+			fetcher = lib.NewFetcher(fetcherOpts...)
 			extractor = lib.NewExtractor(fetcher)
+			extractor.Workers = workers
+			extractor.UseArchiveAPI = useAPI
+			if listingTTL > 0 {
+				cacheDir, err := listingCacheDir()
+				if err != nil {
+					if verbose {
+						fmt.Println("Not caching the archive listing:", err)
+					}
+				} else {
+					extractor.ListingCache = &lib.ListingCache{Dir: cacheDir, TTL: listingTTL, Refresh: noCache}
+				}
+			}
 		},
 	}
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// On the first interrupt signal, the shared context is cancelled so that commands can stop gracefully;
+// a second interrupt terminates the program immediately.
 func Execute() {
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx = signalCtx
+	go func() {
+		<-signalCtx.Done()
+		stop()
+	}()
+
 	err := rootCmd.Execute()
+	if stopDeadline != nil {
+		stopDeadline()
+	}
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -105,18 +219,87 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&proxyURL, "proxy", "x", "", "Specify the proxy url")
 	rootCmd.PersistentFlags().Var(&idCookieName, "cookie_name", "Either \"substack.sid\" or \"connect.sid\", based on the cookie you have (required for private newsletters)")
 	rootCmd.PersistentFlags().StringVar(&idCookieVal, "cookie_val", "", "The substack.sid/connect.sid cookie value (required for private newsletters)")
+	rootCmd.PersistentFlags().StringVar(&cookieFile, "cookie-file", "", "A file with the substack.sid/connect.sid cookie, used instead of --cookie_name and --cookie_val to keep it out of the shell history: either a Netscape cookie jar, as exported from the browser, or a name=value line")
+	rootCmd.PersistentFlags().StringVar(&cookiesFile, "cookies-file", "", "A JSON file mapping the hosts of private publications to their own cookie, used instead of --cookie_name and --cookie_val for them (e.g. {\"example.substack.com\": {\"name\": \"substack.sid\", \"value\": \"...\"}})")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 	rootCmd.PersistentFlags().IntVarP(&ratePerSecond, "rate", "r", lib.DefaultRatePerSecond, "Specify the rate of requests per second")
-	rootCmd.PersistentFlags().StringVar(&beforeDate, "before", "", "Download posts published before this date (format: YYYY-MM-DD)")
-	rootCmd.PersistentFlags().StringVar(&afterDate, "after", "", "Download posts published after this date (format: YYYY-MM-DD)")
+	rootCmd.PersistentFlags().BoolVar(&adaptiveRate, "adaptive-rate", false, "Automatically slow down when the server answers with too many requests, and speed back up to --rate afterwards")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", lib.DefaultUserAgent, "Specify the User-Agent header sent with the requests")
+	rootCmd.PersistentFlags().DurationVar(&minDelay, "min-delay", 0, "Specify the minimum time between two consecutive requests to the same website (e.g. 500ms), on top of --rate")
+	rootCmd.PersistentFlags().IntSliceVar(&retryStatus, "retry-status", lib.DefaultRetryableStatusCodes, "Specify the HTTP status codes, besides 429, for which a request is retried (e.g. 500,502,503,504)")
+	rootCmd.PersistentFlags().StringVar(&apiToken, "api-token", "", "The Substack API token, sent with the API requests (comments, notes, subscriptions, profiles, post bodies) as an alternative to the cookie")
+	rootCmd.PersistentFlags().BoolVar(&insecureTLS, "insecure-skip-tls-verify", false, "Don't verify the TLS certificates of the websites (insecure: use it only for websites with an expired or mismatched certificate)")
+	rootCmd.PersistentFlags().DurationVar(&maxDuration, "max-duration", 0, "Stop after running for this time (e.g. 2h), keeping the posts downloaded so far, like on an interrupt (0 for no limit)")
+	rootCmd.PersistentFlags().Int64Var(&maxRequests, "max-requests", 0, "Stop after sending this number of requests, retries included (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&beforeDate, "before", "", "Download posts published before this date (format: YYYY-MM-DD, or relative to today, e.g. 7d or 2w)")
+	rootCmd.PersistentFlags().StringVar(&afterDate, "after", "", "Download posts published after this date (format: YYYY-MM-DD, or relative to today, e.g. 7d or 2w)")
+	rootCmd.PersistentFlags().StringArrayVar(&tags, "tag", nil, "Download only the posts with this tag, by name or slug (case-insensitive). Can be repeated to match any of several tags. Each post of the archive has to be fetched to know its tags")
+	rootCmd.PersistentFlags().IntVar(&workers, "workers", lib.DefaultWorkers, "Specify how many posts to download at the same time")
+	rootCmd.PersistentFlags().BoolVar(&workersAuto, "workers-auto", false, "Derive the number of workers from --rate and the number of CPUs, instead of using --workers")
+	rootCmd.PersistentFlags().BoolVar(&useAPI, "use-api", false, "Also list the posts through the archive API of the publication, page by page, for the publications whose sitemap is incomplete (it is used anyway when the sitemap lists no posts)")
+	rootCmd.PersistentFlags().DurationVar(&listingTTL, "listing-cache-ttl", 0, "Reuse the archive listing fetched by a previous command within this time (e.g. 10m), instead of fetching the sitemap again (0 to disable)")
+	rootCmd.PersistentFlags().StringVar(&httpCacheDir, "cache-dir", "", "Store the fetched pages in this directory, and only download them again once changed, based on their ETag and Last-Modified headers")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Ignore the cached archive listing and fetch it again")
 	rootCmd.MarkFlagsRequiredTogether("cookie_name", "cookie_val")
+	rootCmd.MarkFlagsMutuallyExclusive("workers", "workers-auto")
 
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(exportOPMLCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(listPublicationsCmd)
+	rootCmd.AddCommand(notesCmd)
+	rootCmd.AddCommand(probeCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// listingCacheDir returns the directory where the archive listings are cached, in the user cache directory.
+func listingCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sbstck-dl"), nil
+}
+
+// autoWorkers returns a number of workers suited to the given rate of requests per second.
+// Every request takes about a second or two to complete, so twice the rate is enough to keep the rate limiter busy:
+// any more workers would just wait for it. The result is capped at 4 workers per CPU, to bound the parsing load.
+func autoWorkers(ratePerSecond int) int {
+	n := 2 * ratePerSecond
+	if limit := 4 * runtime.NumCPU(); n > limit {
+		n = limit
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// relativeDateRegex matches the relative dates accepted by --before and --after, e.g. 7d or 2w.
+var relativeDateRegex = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// resolveDate converts a relative date, a number of days (d) or weeks (w) before now, to the YYYY-MM-DD format.
+// Any other value is returned as is.
+func resolveDate(date string, now time.Time) string {
+	m := relativeDateRegex.FindStringSubmatch(strings.TrimSpace(date))
+	if m == nil {
+		return date
+	}
+	days, err := strconv.Atoi(m[1])
+	if err != nil {
+		return date
+	}
+	if m[2] == "w" {
+		days *= 7
+	}
+	return now.AddDate(0, 0, -days).Format("2006-01-02")
+}
+
 func makeDateFilterFunc(beforeDate string, afterDate string) lib.DateFilterFunc {
+	now := time.Now()
+	beforeDate = resolveDate(beforeDate, now)
+	afterDate = resolveDate(afterDate, now)
+
 	var dateFilterFunc lib.DateFilterFunc
 	if beforeDate != "" && afterDate != "" {
 		dateFilterFunc = func(date string) bool {
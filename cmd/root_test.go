@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveDate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"7d", "2024-03-08"},
+		{"2w", "2024-03-01"},
+		{"0d", "2024-03-15"},
+		{"30d", "2024-02-14"},
+		{" 1d ", "2024-03-14"},
+		{"2024-01-01", "2024-01-01"},
+		{"", ""},
+		{"7h", "7h"},
+	}
+	for _, tt := range tests {
+		if got := resolveDate(tt.date, now); got != tt.want {
+			t.Errorf("resolveDate(%q) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestMakeDateFilterFuncRelative(t *testing.T) {
+	daysAgo := func(n int) string {
+		return time.Now().AddDate(0, 0, -n).Format("2006-01-02")
+	}
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		date   string
+		want   bool
+	}{
+		{"after 7d, recent post", "", "7d", daysAgo(2), true},
+		{"after 7d, old post", "", "7d", daysAgo(10), false},
+		{"after 2w, post of last week", "", "2w", daysAgo(10), true},
+		{"before 7d, old post", "7d", "", daysAgo(10), true},
+		{"before 7d, recent post", "7d", "", daysAgo(2), false},
+		{"between 2w and 7d", "7d", "2w", daysAgo(10), true},
+		{"absolute date", "", "2024-01-01", "2024-02-01", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := makeDateFilterFunc(tt.before, tt.after)
+			if got := filter(tt.date); got != tt.want {
+				t.Errorf("filter(%s) = %v, want %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexferrari88/sbstck-dl/lib"
+)
+
+// defaultOutputTemplate is the layout of the post files when no --output-template is given.
+const defaultOutputTemplate = "{date}_{slug}.{ext}"
+
+// maxTitleLength is the maximum number of characters of the {title} placeholder, to keep the file names within the limits of the filesystems.
+const maxTitleLength = 100
+
+// templatePlaceholderRegex matches the placeholders of an output template, capturing their name.
+var templatePlaceholderRegex = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// templatePlaceholders lists the placeholders an output template can use.
+var templatePlaceholders = map[string]bool{
+	"date": true, "year": true, "month": true, "day": true, "slug": true, "title": true, "id": true, "ext": true,
+}
+
+// validateOutputTemplate checks that the output template only uses known placeholders,
+// stays within the output folder, and ends with the extension of the format, which the companion files are named after.
+func validateOutputTemplate(tmpl string) error {
+	for _, match := range templatePlaceholderRegex.FindAllStringSubmatch(tmpl, -1) {
+		if !templatePlaceholders[match[1]] {
+			return fmt.Errorf("invalid output template %q: unknown placeholder %s", tmpl, match[0])
+		}
+	}
+	if !strings.HasSuffix(tmpl, ".{ext}") {
+		return fmt.Errorf("invalid output template %q: it must end with .{ext}", tmpl)
+	}
+	if filepath.IsAbs(tmpl) || strings.HasPrefix(tmpl, "/") {
+		return fmt.Errorf("invalid output template %q: it must be relative to the output folder", tmpl)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(tmpl), "/") {
+		if segment == ".." || segment == "" {
+			return fmt.Errorf("invalid output template %q: it must stay within the output folder", tmpl)
+		}
+	}
+	return nil
+}
+
+// renderOutputTemplate returns the path of the post file, relative to the output folder, following the output template.
+func renderOutputTemplate(tmpl string, post lib.Post, format string) string {
+	var year, month, day string
+	if postDate, err := time.Parse(time.RFC3339, post.PostDate); err == nil {
+		year, month, day = postDate.Format("2006"), postDate.Format("01"), postDate.Format("02")
+	}
+	values := map[string]string{
+		"date":  convertDateTime(post.PostDate),
+		"year":  year,
+		"month": month,
+		"day":   day,
+		"slug":  fileSlug(post.Slug),
+		"title": fileTitle(post.Title),
+		"id":    strconv.Itoa(post.Id),
+		"ext":   format,
+	}
+	path := templatePlaceholderRegex.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		return values[placeholder[1:len(placeholder)-1]]
+	})
+	return filepath.FromSlash(path)
+}
+
+// fileTitle returns the title as used in file names: the characters which are not allowed in file names,
+// such as path separators, are replaced by dashes, and the title is folded to ASCII with --ascii-filenames.
+func fileTitle(title string) string {
+	if asciiNames {
+		title = asciiFilename(title)
+	}
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '-'
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength])
+	}
+	// Windows doesn't allow the names ending with a dot or a space
+	title = strings.TrimRight(title, ". ")
+	if title == "" {
+		return "untitled"
+	}
+	return title
+}
+
+// outputTemplateGlob returns the pattern matching the files of the post with the slug laid out with the output template,
+// whatever their date, title and id, or an empty string if the template has no {slug} to match them by.
+func outputTemplateGlob(tmpl string, slug string, format string) string {
+	if !strings.Contains(tmpl, "{slug}") {
+		return ""
+	}
+	return filepath.FromSlash(templatePlaceholderRegex.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		switch placeholder {
+		case "{slug}":
+			return slug
+		case "{ext}":
+			return format
+		}
+		return "*"
+	}))
+}
+
+// outputTemplateRegex returns the regular expression matching the paths of the post files laid out with the output template,
+// relative to the output folder and with forward slashes, capturing their slug. It returns nil if the template has no {slug}.
+func outputTemplateRegex(tmpl string, format string) *regexp.Regexp {
+	if !strings.Contains(tmpl, "{slug}") {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	slugCaptured := false
+	for _, loc := range templatePlaceholderRegex.FindAllStringIndex(tmpl, -1) {
+		sb.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		switch tmpl[loc[0]:loc[1]] {
+		case "{slug}":
+			if slugCaptured {
+				sb.WriteString("[^/]+")
+			} else {
+				sb.WriteString("([^/]+)")
+				slugCaptured = true
+			}
+		case "{ext}":
+			sb.WriteString(regexp.QuoteMeta(format))
+		case "{date}":
+			sb.WriteString(`(?:\d{8}_\d{6})?`)
+		case "{year}":
+			sb.WriteString(`(?:\d{4})?`)
+		case "{month}", "{day}":
+			sb.WriteString(`(?:\d{2})?`)
+		case "{id}":
+			sb.WriteString(`\d+`)
+		default:
+			sb.WriteString("[^/]*")
+		}
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
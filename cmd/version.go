@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/alexferrari88/sbstck-dl/lib"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +13,7 @@ var versionCmd = &cobra.Command{
 	Short: "Print the version number of sbstck-dl",
 	Long:  `Display the current version of the app.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("sbstck-dl v0.3.2")
+		fmt.Println("sbstck-dl v" + lib.Version)
 	},
 }
 
@@ -0,0 +1,44 @@
+package cmd
+
+import "fmt"
+
+// warmUp sends a warm-up request to the publication at pubUrl before downloading its archive,
+// reports how the server answers, and lowers the rate of the requests if it suggests so.
+func warmUp(pubUrl string) error {
+	result, err := extractor.WarmUp(ctx, pubUrl)
+	if err != nil {
+		return err
+	}
+	if verbose {
+		fmt.Printf("Warm-up: status %d", result.StatusCode)
+		if result.Server != "" {
+			fmt.Printf(", server %q", result.Server)
+		}
+		if result.CDN != "" {
+			fmt.Printf(", behind %s", result.CDN)
+		}
+		fmt.Println()
+		if result.RetryAfter > 0 {
+			fmt.Printf("Warm-up: the server asks to slow down, waiting %s before the download\n", result.RetryAfter)
+		}
+		if fetcher.CookieFor(publicationHost(pubUrl)) != nil {
+			if result.Authenticated {
+				fmt.Println("Warm-up: cookie recognized")
+			} else {
+				fmt.Println("Warm-up: the cookie was not recognized, the private posts will be previews")
+			}
+		}
+	}
+	if current := fetcher.RateLimiter.Limit(); result.SuggestedRate < current {
+		if fetcher.AdaptiveRate != nil {
+			// the adaptive rate would otherwise speed back up to --rate
+			fetcher.AdaptiveRate.SetMaxRate(result.SuggestedRate)
+		} else {
+			fetcher.RateLimiter.SetLimit(result.SuggestedRate)
+		}
+		if verbose {
+			fmt.Printf("Warm-up: lowering the rate from %g to %g requests per second\n", float64(current), float64(result.SuggestedRate))
+		}
+	}
+	return nil
+}
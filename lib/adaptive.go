@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// adaptiveMinRate is the lowest rate, in requests per second, an AdaptiveLimiter can slow down to.
+const adaptiveMinRate = rate.Limit(0.1)
+
+// adaptiveSuccessWindow is the number of consecutive successful requests after which an AdaptiveLimiter speeds up.
+const adaptiveSuccessWindow = 10
+
+// AdaptiveLimiter adjusts the rate of a rate.Limiter based on the outcome of the requests,
+// with an additive increase/multiplicative decrease (AIMD) strategy:
+// the rate is halved every time the server answers with too many requests,
+// and it is increased by a tenth of the maximum rate after every adaptiveSuccessWindow successful requests in a row,
+// up to the maximum rate.
+type AdaptiveLimiter struct {
+	limiter   *rate.Limiter
+	maxRate   rate.Limit
+	successes int
+	mu        sync.Mutex
+}
+
+// NewAdaptiveLimiter creates a new AdaptiveLimiter adjusting the rate of limiter.
+// The current rate of limiter is used as both the starting and the maximum rate.
+func NewAdaptiveLimiter(limiter *rate.Limiter) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		limiter: limiter,
+		maxRate: limiter.Limit(),
+	}
+}
+
+// OnTooManyRequests halves the rate, down to adaptiveMinRate.
+func (a *AdaptiveLimiter) OnTooManyRequests() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.successes = 0
+	newRate := a.limiter.Limit() / 2
+	if newRate < adaptiveMinRate {
+		newRate = adaptiveMinRate
+	}
+	a.limiter.SetLimit(newRate)
+}
+
+// OnSuccess records a successful request, increasing the rate after adaptiveSuccessWindow successes in a row.
+func (a *AdaptiveLimiter) OnSuccess() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.successes++
+	if a.successes < adaptiveSuccessWindow {
+		return
+	}
+	a.successes = 0
+	newRate := a.limiter.Limit() + a.maxRate/10
+	if newRate > a.maxRate {
+		newRate = a.maxRate
+	}
+	a.limiter.SetLimit(newRate)
+}
+
+// SetMaxRate changes the maximum rate the AdaptiveLimiter speeds up to, e.g. to a lower rate suggested by the server,
+// and slows the current rate down to it if needed.
+func (a *AdaptiveLimiter) SetMaxRate(maxRate rate.Limit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maxRate = maxRate
+	if a.limiter.Limit() > maxRate {
+		a.limiter.SetLimit(maxRate)
+	}
+}
+
+// Limit returns the current rate of the underlying limiter.
+func (a *AdaptiveLimiter) Limit() rate.Limit {
+	return a.limiter.Limit()
+}
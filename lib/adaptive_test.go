@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/time/rate"
+)
+
+func TestAdaptiveLimiterSetMaxRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxRate rate.Limit
+		want    rate.Limit
+	}{
+		{"lower", 2, 2},
+		{"higher", 20, 20},
+		{"same", 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdaptiveLimiter(rate.NewLimiter(10, 1))
+			a.SetMaxRate(tt.maxRate)
+			a.OnTooManyRequests()
+			// speeding up for long enough reaches the maximum rate, and no more
+			for i := 0; i < 50*adaptiveSuccessWindow; i++ {
+				a.OnSuccess()
+			}
+			if got := a.Limit(); got != tt.want {
+				t.Errorf("rate after ramping up = %g, want %g", float64(got), float64(tt.want))
+			}
+		})
+	}
+}
+
+func TestFetcherAdaptiveRate(t *testing.T) {
+	tooManyRequests := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tooManyRequests {
+			tooManyRequests = false
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	const maxRate = 1000
+	f := NewFetcher(WithRatePerSecond(maxRate), WithAdaptiveRate(), WithBackOffConfig(backoff.NewConstantBackOff(time.Millisecond)))
+	fetch := func() {
+		body, err := f.FetchURL(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body.Close()
+	}
+
+	// the first request gets a 429, and is retried
+	fetch()
+	if got := f.RateLimiter.Limit(); got != maxRate/2 {
+		t.Fatalf("rate after a 429 = %g, want %d", float64(got), maxRate/2)
+	}
+
+	tests := []struct {
+		successes int
+		want      rate.Limit
+	}{
+		{adaptiveSuccessWindow - 1, maxRate / 2},
+		{adaptiveSuccessWindow, maxRate/2 + maxRate/10},
+		{5 * adaptiveSuccessWindow, maxRate},
+		{10 * adaptiveSuccessWindow, maxRate},
+	}
+	successes := 1 // the retried request
+	for _, tt := range tests {
+		for ; successes < tt.successes; successes++ {
+			fetch()
+		}
+		if got := f.RateLimiter.Limit(); got != tt.want {
+			t.Errorf("rate after %d successes = %g, want %g", tt.successes, float64(got), float64(tt.want))
+		}
+	}
+}
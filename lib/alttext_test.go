@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestToMDImageAltText(t *testing.T) {
+	body, err := os.ReadFile("testdata/alt-text-body.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := Post{BodyHTML: string(body)}
+	got, err := post.ToMD(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"![A cat on a sofa](https://substackcdn.com/image/fetch/cat.png)",
+		"![Sales by quarter](https://substackcdn.com/image/fetch/chart.png)",
+		"![my dog](https://substack-post-media.s3.amazonaws.com/public/images/my-dog_1024x768.jpeg?w=1456)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToMD() is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "![]") {
+		t.Errorf("ToMD() has images without description:\n%s", got)
+	}
+}
+
+func TestAltTextFromURL(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"https://example.com/images/my-cat_1024x768.jpg", "my cat"},
+		{"https://example.com/images/summer%20trip.png?w=800", "summer trip"},
+		{"https://example.com/images/photo.webp", "photo"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := altTextFromURL(tt.src); got != tt.want {
+			t.Errorf("altTextFromURL(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
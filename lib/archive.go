@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// archivePageSize is the number of posts requested per page of the archive API, the most it returns.
+const archivePageSize = 50
+
+// PostSummary is the metadata of a post, without its content, as listed by the archive.
+type PostSummary struct {
+	Title string `json:"title"`
+	// Date is the publication date of the post, in RFC 3339 format.
+	Date string `json:"date"`
+	Slug string `json:"slug"`
+	Url  string `json:"url"`
+}
+
+// archivePost is a post as listed by the archive API, with only the fields needed for the listing.
+type archivePost struct {
+	Title        string `json:"title"`
+	Slug         string `json:"slug"`
+	CanonicalUrl string `json:"canonical_url"`
+	PostDate     string `json:"post_date"`
+}
+
+// getArchivePosts returns the posts listed by the archive API of the publication at pubUrl,
+// requesting its pages until it has no more posts.
+func (e *Extractor) getArchivePosts(ctx context.Context, pubUrl string) ([]archivePost, error) {
+	u, err := url.Parse(pubUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []archivePost
+	for offset := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return posts, err
+		}
+		var page []archivePost
+		apiUrl := fmt.Sprintf("%s://%s/api/v1/archive?sort=new&offset=%d&limit=%d", u.Scheme, u.Host, offset, archivePageSize)
+		if err := e.fetchJSON(ctx, apiUrl, &page); err != nil {
+			return posts, err
+		}
+		for _, p := range page {
+			if strings.Contains(p.CanonicalUrl, "/p/") {
+				posts = append(posts, p)
+			}
+		}
+		if len(page) < archivePageSize {
+			return posts, nil
+		}
+		offset += len(page)
+	}
+}
+
+// getArchiveListing returns the posts listed by the archive API of the publication at pubUrl.
+// Their date is the publication date, in the YYYY-MM-DD format of the sitemap.
+func (e *Extractor) getArchiveListing(ctx context.Context, pubUrl string) ([]ListingEntry, error) {
+	posts, err := e.getArchivePosts(ctx, pubUrl)
+	if err != nil {
+		return nil, err
+	}
+	entries := []ListingEntry{}
+	for _, p := range posts {
+		lastmod := p.PostDate
+		if len(lastmod) > len("2006-01-02") {
+			lastmod = lastmod[:len("2006-01-02")]
+		}
+		entries = append(entries, ListingEntry{Url: p.CanonicalUrl, Lastmod: lastmod})
+	}
+	return entries, nil
+}
+
+// GetPostSummaries returns the metadata of the posts at urls, e.g. as returned by GetAllPostsURLs, in the same order.
+// It is read from the archive API of the publication at pubUrl, a page of posts at a time, and only the posts
+// missing from it are extracted one by one. The posts which fail to extract are left out.
+func (e *Extractor) GetPostSummaries(ctx context.Context, pubUrl string, urls []string) ([]PostSummary, error) {
+	bySlug := make(map[string]PostSummary)
+	// without the API, every post is extracted
+	if posts, err := e.getArchivePosts(ctx, pubUrl); err == nil {
+		for _, p := range posts {
+			slug := p.Slug
+			if slug == "" {
+				slug = slugFromURL(p.CanonicalUrl)
+			}
+			bySlug[slug] = PostSummary{Title: p.Title, Date: p.PostDate, Slug: slug, Url: p.CanonicalUrl}
+		}
+	}
+
+	var missing []string
+	for _, u := range urls {
+		if _, ok := bySlug[slugFromURL(u)]; !ok {
+			missing = append(missing, u)
+		}
+	}
+	for result := range e.ExtractAllPosts(ctx, missing) {
+		if result.Err != nil {
+			continue
+		}
+		bySlug[slugFromURL(result.Url)] = PostSummary{Title: result.Post.Title, Date: result.Post.PostDate, Slug: result.Post.Slug, Url: result.Post.CanonicalUrl}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := []PostSummary{}
+	for _, u := range urls {
+		if summary, ok := bySlug[slugFromURL(u)]; ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+// mergeListings returns the entries of listing followed by the ones of others which are not in it,
+// the posts being compared by their slug, which is unique within a publication whatever its domain.
+func mergeListings(listing []ListingEntry, others []ListingEntry) []ListingEntry {
+	seen := make(map[string]bool, len(listing))
+	for _, entry := range listing {
+		seen[slugFromURL(entry.Url)] = true
+	}
+	for _, entry := range others {
+		if key := slugFromURL(entry.Url); !seen[key] {
+			seen[key] = true
+			listing = append(listing, entry)
+		}
+	}
+	return listing
+}
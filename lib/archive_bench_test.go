@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// BenchmarkExtractAllPostsMemory extracts a large synthetic archive, dropping each post once it is received
+// like the download loop does once it is written, and reports the peak heap in use while doing it.
+// The peak must stay about the same whatever the number of posts: only the posts in flight are held.
+func BenchmarkExtractAllPostsMemory(b *testing.B) {
+	body := "<p>" + strings.Repeat("Lorem ipsum dolor sit amet. ", 4000) + "</p>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := strings.TrimPrefix(r.URL.Path, "/p/")
+		w.Write([]byte(preloadsPage(b, map[string]any{
+			"post": map[string]any{"id": 1, "title": slug, "slug": slug, "body_html": body},
+		})))
+	}))
+	defer srv.Close()
+
+	for _, posts := range []int{200, 1000} {
+		for _, keepRaw := range []bool{false, true} {
+			b.Run(fmt.Sprintf("posts=%d/keepRaw=%t", posts, keepRaw), func(b *testing.B) {
+				urls := make([]string, posts)
+				for i := range urls {
+					urls[i] = fmt.Sprintf("%s/p/post-%d", srv.URL, i)
+				}
+				e := NewExtractor(NewFetcher(WithRatePerSecond(1000000)))
+				e.KeepRaw = keepRaw
+				b.ReportAllocs()
+				b.ResetTimer()
+
+				var peak uint64
+				var stats runtime.MemStats
+				for i := 0; i < b.N; i++ {
+					n := 0
+					for res := range e.ExtractAllPosts(context.Background(), urls) {
+						if res.Err != nil {
+							b.Fatal(res.Err)
+						}
+						if n++; n%50 == 0 {
+							runtime.ReadMemStats(&stats)
+							if stats.HeapInuse > peak {
+								peak = stats.HeapInuse
+							}
+						}
+					}
+				}
+				b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+			})
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IndexEntry is a downloaded post listed in the index of the archive.
+type IndexEntry struct {
+	Title string `json:"title"`
+	// Date is the publication date of the post, in RFC 3339 format.
+	Date string `json:"date"`
+	Url  string `json:"url"`
+	// Path is the path of the post file, relative to the index, with forward slashes.
+	Path string `json:"path"`
+}
+
+// ArchiveIndex lists the downloaded posts of an archive, newest first, to browse them from a single page.
+// It is stored as JSON next to the pages written from it, so that the incremental runs add their posts to it.
+type ArchiveIndex struct {
+	Title   string       `json:"title"`
+	Entries []IndexEntry `json:"posts"`
+}
+
+// ReadArchiveIndex reads the index stored as JSON at path by WriteJSON.
+// A missing file is an empty index.
+func ReadArchiveIndex(path string) (*ArchiveIndex, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ArchiveIndex{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx ArchiveIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, fmt.Errorf("invalid archive index %s: %w", path, err)
+	}
+	return &idx, nil
+}
+
+// Add lists the entry in the index, replacing the entry of the same post or file, if any.
+func (idx *ArchiveIndex) Add(entry IndexEntry) {
+	for i, e := range idx.Entries {
+		if e.Url == entry.Url || e.Path == entry.Path {
+			idx.Entries[i] = entry
+			return
+		}
+	}
+	idx.Entries = append(idx.Entries, entry)
+}
+
+// RemoveMissing removes the entries whose file is no longer in dir, the folder of the index,
+// e.g. the posts moved away by a prune, and returns them.
+func (idx *ArchiveIndex) RemoveMissing(dir string) []IndexEntry {
+	var kept, removed []IndexEntry
+	for _, e := range idx.Entries {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(e.Path))); err != nil {
+			removed = append(removed, e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	idx.Entries = kept
+	return removed
+}
+
+// sort orders the entries by date, newest first, and by title for the same date.
+func (idx *ArchiveIndex) sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		if idx.Entries[i].Date != idx.Entries[j].Date {
+			return idx.Entries[i].Date > idx.Entries[j].Date
+		}
+		return idx.Entries[i].Title < idx.Entries[j].Title
+	})
+}
+
+// WriteJSON writes the index as JSON to a file, to be read back by ReadArchiveIndex.
+func (idx *ArchiveIndex) WriteJSON(path string) error {
+	idx.sort()
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, string(b))
+}
+
+// WriteHTML writes the index as an HTML page to a file, listing the posts with their date and a link to their file.
+func (idx *ArchiveIndex) WriteHTML(path string) error {
+	idx.sort()
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n", html.EscapeString(idx.Title))
+	sb.WriteString("<style>\n" + archiveIndexStyle + "\n</style>\n")
+	sb.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<ul>\n", html.EscapeString(idx.Title))
+	for _, e := range idx.Entries {
+		fmt.Fprintf(&sb, "<li><time datetime=\"%s\">%s</time> <a href=\"%s\">%s</a></li>\n",
+			html.EscapeString(e.Date), html.EscapeString(indexDate(e.Date)), html.EscapeString(indexHref(e.Path)), html.EscapeString(e.Title))
+	}
+	sb.WriteString("</ul>\n</body>\n</html>\n")
+	return writeFile(path, sb.String())
+}
+
+// WriteMarkdown writes the index as a Markdown list to a file, listing the posts with their date and a link to their file.
+func (idx *ArchiveIndex) WriteMarkdown(path string) error {
+	idx.sort()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", escapeMarkdownText(idx.Title))
+	for _, e := range idx.Entries {
+		fmt.Fprintf(&sb, "- %s [%s](<%s>)\n", indexDate(e.Date), escapeMarkdownText(e.Title), indexHref(e.Path))
+	}
+	return writeFile(path, sb.String())
+}
+
+// indexDate returns the day of the RFC 3339 date, e.g. 2023-01-31.
+func indexDate(date string) string {
+	if len(date) >= len("2006-01-02") {
+		return date[:len("2006-01-02")]
+	}
+	return date
+}
+
+// indexHref returns the relative URL of the file at path, relative to the index.
+func indexHref(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapeMarkdownText escapes the characters of text which Markdown would take for link or emphasis delimiters.
+func escapeMarkdownText(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\[]*_`<>#", r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// archiveIndexStyle is the stylesheet of the archive index.
+const archiveIndexStyle = `body { max-width: 48rem; margin: 2rem auto; padding: 0 1rem; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Arial, sans-serif; }
+ul { list-style: none; padding: 0; }
+li { margin: 0.4rem 0; }
+time { display: inline-block; min-width: 6.5rem; color: #666; font-variant-numeric: tabular-nums; }`
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// AudioURLs returns the URLs of the audio files of the Post, without duplicates:
+// the episode of podcast posts, followed by the audio players embedded in its body.
+func (p *Post) AudioURLs() ([]string, error) {
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(u string) {
+		if u != "" && !seen[u] && (strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")) {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	add(p.PodcastUrl)
+	doc.Find("audio[src], audio source[src]").Each(func(i int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""))
+	})
+	return urls, nil
+}
+
+// DownloadAudio downloads the audio files of the Post next to it, to basePath with the extension of each file added,
+// and numbered from the second one on, e.g. post.audio.mp3, post.audio-2.mp3.
+// The audio players of the body are pointed to the downloaded files, and a player is added at the top of the body
+// for the podcast episode, so that the post plays its local copy.
+// The files already downloaded are not downloaded again, unless they don't match their checksum with VerifyChecksums.
+// It returns the paths of the files.
+func (e *Extractor) DownloadAudio(ctx context.Context, p *Post, basePath string) ([]string, error) {
+	urls, err := p.AudioURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	local := make(map[string]string)
+	for i, u := range urls {
+		filePath := basePath
+		if i > 0 {
+			filePath = fmt.Sprintf("%s-%d", basePath, i+1)
+		}
+		filePath += audioExtension(u)
+		if !e.isDownloaded(filePath) {
+			if err := e.downloadFile(ctx, u, p.CanonicalUrl, filePath); err != nil {
+				return paths, fmt.Errorf("failed to download audio %s: %w", u, err)
+			}
+		}
+		paths = append(paths, filePath)
+		// the post is written next to the files: they are referenced by name
+		local[u] = url.PathEscape(filepath.Base(filePath))
+	}
+	if len(local) == 0 {
+		return nil, nil
+	}
+
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return paths, err
+	}
+	body := doc.Find("body")
+	episodeInBody := false
+	body.Find("audio[src], audio source[src]").Each(func(i int, s *goquery.Selection) {
+		src := s.AttrOr("src", "")
+		if src == p.PodcastUrl {
+			episodeInBody = true
+		}
+		if localPath, ok := local[src]; ok {
+			s.SetAttr("src", localPath)
+		}
+	})
+	p.BodyHTML, err = body.Html()
+	if err != nil {
+		return paths, err
+	}
+	if localPath, ok := local[p.PodcastUrl]; ok && !episodeInBody {
+		// the link is kept by the formats which have no player, like md
+		p.BodyHTML = fmt.Sprintf("<figure class=\"podcast\"><audio controls src=\"%s\"></audio><figcaption><a href=\"%s\">Listen to the episode</a></figcaption></figure>\n",
+			html.EscapeString(localPath), html.EscapeString(localPath)) + p.BodyHTML
+	}
+	return paths, nil
+}
+
+// downloadFile streams the resource at fileUrl, used in the page at referer, to the file at filePath.
+// The Fetcher only sends its credentials to the publication and to Substack, e.g. for the audio of paid podcasts,
+// not to the CDNs the audio is usually served from.
+// With VerifyChecksums, the SHA-256 sum of the file, computed while streaming it, is recorded in a sidecar file once it is written.
+func (e *Extractor) downloadFile(ctx context.Context, fileUrl string, referer string, filePath string) error {
+	res, err := e.fetcher.FetchURLFull(ctx, fileUrl, WithReferer(referer))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	h := sha256.New()
+	if err := writeFileFrom(filePath, io.TeeReader(res.Body, h)); err != nil {
+		return err
+	}
+	if e.VerifyChecksums {
+		return writeChecksumFile(filePath, h.Sum(nil))
+	}
+	return nil
+}
+
+// isDownloaded reports whether the file at filePath was already downloaded and, with VerifyChecksums,
+// whether it matches its recorded checksum: a file which doesn't, or has none, is to be downloaded again.
+func (e *Extractor) isDownloaded(filePath string) bool {
+	if _, err := os.Stat(filePath); err != nil {
+		return false
+	}
+	if !e.VerifyChecksums {
+		return true
+	}
+	ok, err := verifyChecksum(filePath)
+	return err == nil && ok
+}
+
+// audioExtension returns the file extension of the audio file at audioUrl, which defaults to .mp3.
+func audioExtension(audioUrl string) string {
+	if u, err := url.Parse(audioUrl); err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".mp3", ".m4a", ".aac", ".ogg", ".oga", ".opus", ".wav", ".flac":
+			return ext
+		}
+	}
+	return ".mp3"
+}
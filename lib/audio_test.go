@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadAudioSendsNoCredentialsToAudioHosts(t *testing.T) {
+	var gotCredentials bool
+	srv := credentialServer(t, &gotCredentials)
+	e := NewExtractor(NewFetcher(WithCookie(&http.Cookie{Name: "substack.sid", Value: "secret"}), WithCookieHosts("localhost")))
+	p := &Post{CanonicalUrl: "http://localhost/p/episode", PodcastUrl: srv.URL + "/episode.mp3"}
+	basePath := filepath.Join(t.TempDir(), "post.audio")
+
+	paths, err := e.DownloadAudio(context.Background(), p, basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotCredentials {
+		t.Error("DownloadAudio sent the credentials to the audio host")
+	}
+	if len(paths) != 1 || paths[0] != basePath+".mp3" {
+		t.Fatalf("DownloadAudio returned %v", paths)
+	}
+	if b, err := os.ReadFile(paths[0]); err != nil || string(b) != "data" {
+		t.Errorf("audio file = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// ExtractChain extracts the post at postUrl along with the posts linked to it, walking the chain of
+// previous and next posts (PreviousPostSlug and NextPostSlug) in both directions, e.g. to get a whole series.
+// The posts are returned from the first to the last of the chain. The walk stops at empty slugs
+// and at posts already seen, so that a cycle in the chain doesn't make it loop forever.
+func (e *Extractor) ExtractChain(ctx context.Context, postUrl string) ([]Post, error) {
+	start, err := e.ExtractPost(ctx, postUrl)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(start.CanonicalUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{start.Slug: true}
+	walk := func(slug string, nextSlug func(Post) string) ([]Post, error) {
+		var posts []Post
+		for slug != "" && !seen[slug] {
+			seen[slug] = true
+			post, err := e.ExtractPost(ctx, fmt.Sprintf("%s://%s/p/%s", u.Scheme, u.Host, url.PathEscape(slug)))
+			if err != nil {
+				return posts, err
+			}
+			posts = append(posts, post)
+			slug = nextSlug(post)
+		}
+		return posts, nil
+	}
+
+	previous, err := walk(start.PreviousPostSlug, func(p Post) string { return p.PreviousPostSlug })
+	if err != nil {
+		return nil, err
+	}
+	next, err := walk(start.NextPostSlug, func(p Post) string { return p.NextPostSlug })
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]Post, 0, len(previous)+1+len(next))
+	for i := len(previous) - 1; i >= 0; i-- {
+		chain = append(chain, previous[i])
+	}
+	chain = append(chain, start)
+	return append(chain, next...), nil
+}
@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumExtension is the extension of the sidecar file recording the SHA-256 of a downloaded file, added to its name.
+const checksumExtension = ".sha256"
+
+// writeChecksumFile records the SHA-256 sum of the file at path in its sidecar file,
+// in the format of sha256sum, so that it can also be checked with `sha256sum -c`.
+func writeChecksumFile(path string, sum []byte) error {
+	return writeFile(path+checksumExtension, fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum), filepath.Base(path)))
+}
+
+// verifyChecksum reports whether the file at path matches the SHA-256 sum recorded in its sidecar file.
+// A file without a sidecar file, e.g. one whose download was interrupted, doesn't match.
+func verifyChecksum(path string) (bool, error) {
+	b, err := os.ReadFile(path + checksumExtension)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	recorded, _, _ := strings.Cut(strings.TrimSpace(string(b)), " ")
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(recorded, hex.EncodeToString(h.Sum(nil))), nil
+}
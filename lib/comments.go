@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CommentUser represents the author of a Substack comment or note.
+type CommentUser struct {
+	Id       int    `json:"user_id"`
+	Name     string `json:"name"`
+	Handle   string `json:"handle"`
+	PhotoUrl string `json:"photo_url"`
+}
+
+// Comment represents a single comment on a Substack post, along with its replies.
+// Children holds the direct replies to the comment, each of which can have replies of its own.
+type Comment struct {
+	Id            int         `json:"id"`
+	PostId        int         `json:"post_id"`
+	Body          string      `json:"body"`
+	Date          string      `json:"date"`
+	EditedAt      string      `json:"edited_at,omitempty"`
+	ReactionCount int         `json:"reaction_count"`
+	User          CommentUser `json:"user"`
+	Children      []Comment   `json:"children,omitempty"`
+}
+
+// rawComment mirrors the comment objects returned by the Substack API,
+// where the author fields are inlined in the comment itself.
+type rawComment struct {
+	CommentUser
+	Id            int          `json:"id"`
+	PostId        int          `json:"post_id"`
+	Body          string       `json:"body"`
+	Date          string       `json:"date"`
+	EditedAt      string       `json:"edited_at"`
+	ReactionCount int          `json:"reaction_count"`
+	Children      []rawComment `json:"children"`
+}
+
+// toComment converts the rawComment, and all its replies, to a Comment.
+func (r rawComment) toComment() Comment {
+	c := Comment{
+		Id:            r.Id,
+		PostId:        r.PostId,
+		Body:          r.Body,
+		Date:          r.Date,
+		EditedAt:      r.EditedAt,
+		ReactionCount: r.ReactionCount,
+		User:          r.CommentUser,
+	}
+	for _, child := range r.Children {
+		c.Children = append(c.Children, child.toComment())
+	}
+	return c
+}
+
+// ErrCommentsGated is returned when the comments of a post are only accessible to its subscribers,
+// and the Fetcher has no cookie or the cookie of a reader without access.
+var ErrCommentsGated = errors.New("the comments are only accessible to subscribers")
+
+// commentsPageSize is the number of top-level comments requested per page.
+const commentsPageSize = 50
+
+// commentsResponse is a single page of the payload returned by the post comments endpoint.
+type commentsResponse struct {
+	Comments []rawComment `json:"comments"`
+	More     bool         `json:"more"`
+}
+
+// GetComments fetches the post at postUrl and returns all its comments as a tree.
+func (e *Extractor) GetComments(ctx context.Context, postUrl string) ([]Comment, error) {
+	post, err := e.ExtractPost(ctx, postUrl)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetPostComments(ctx, post)
+}
+
+// GetPostComments returns all the comments of an already extracted post as a tree.
+// If the comments are restricted to subscribers and the Fetcher's cookie doesn't grant access to them,
+// the returned error wraps ErrCommentsGated.
+// When the Extractor's CommentsConcurrency is greater than 1, the pages expected from the post comment count
+// are fetched concurrently, still under the Fetcher's rate limit. The comments are returned in their original order either way.
+func (e *Extractor) GetPostComments(ctx context.Context, post Post) ([]Comment, error) {
+	u, err := url.Parse(post.CanonicalUrl)
+	if err != nil {
+		return nil, err
+	}
+	baseUrl := fmt.Sprintf("%s://%s/api/v1/post/%d/comments?all_comments=true&sort=oldest_first&limit=%d", u.Scheme, u.Host, post.Id, commentsPageSize)
+
+	fetchPage := func(ctx context.Context, page int) (commentsResponse, error) {
+		var res commentsResponse
+		pageUrl := fmt.Sprintf("%s&offset=%d", baseUrl, page*commentsPageSize)
+		if err := e.fetchJSON(ctx, pageUrl, &res); err != nil {
+			var fetchErr *FetchError
+			if errors.As(err, &fetchErr) && (fetchErr.StatusCode == http.StatusUnauthorized || fetchErr.StatusCode == http.StatusForbidden) {
+				return res, fmt.Errorf("failed to fetch comments: %w (%s)", ErrCommentsGated, err)
+			}
+			return res, fmt.Errorf("failed to fetch comments: %w", err)
+		}
+		return res, nil
+	}
+
+	// the comment count includes the replies, so it is an upper bound of the top-level comments
+	var pages []commentsResponse
+	if e.CommentsConcurrency > 1 && post.CommentCount > commentsPageSize {
+		pages = make([]commentsResponse, (post.CommentCount+commentsPageSize-1)/commentsPageSize)
+		eg, egCtx := errgroup.WithContext(ctx)
+		eg.SetLimit(e.CommentsConcurrency)
+		for i := range pages {
+			i := i
+			eg.Go(func() error {
+				res, err := fetchPage(egCtx, i)
+				pages[i] = res
+				return err
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+	}
+
+	// fetch the remaining pages, if any, one at a time
+	for len(pages) == 0 || pages[len(pages)-1].More {
+		res, err := fetchPage(ctx, len(pages))
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, res)
+	}
+
+	// new comments posted while fetching shift the pages: skip the ones already seen
+	seen := make(map[int]bool)
+	comments := []Comment{}
+	for _, page := range pages {
+		for _, c := range page.Comments {
+			if seen[c.Id] {
+				continue
+			}
+			seen[c.Id] = true
+			comments = append(comments, c.toComment())
+		}
+	}
+	return comments, nil
+}
+
+// RenderComments renders the comments, and all their replies, in the specified format (json, html, md, txt, or org).
+func RenderComments(comments []Comment, format string) (string, error) {
+	var sb strings.Builder
+	switch format {
+	case "json":
+		b, err := json.Marshal(comments)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case "html":
+		sb.WriteString("<h2>Comments</h2>\n")
+		writeCommentsHTML(&sb, comments)
+	case "md":
+		sb.WriteString("## Comments\n\n")
+		writeCommentsMD(&sb, comments, 0)
+	case "txt":
+		sb.WriteString("Comments\n\n")
+		writeCommentsText(&sb, comments, 0)
+	case "org":
+		sb.WriteString("* Comments\n\n")
+		writeCommentsOrg(&sb, comments, 0)
+	default:
+		return "", fmt.Errorf("unknown comment format: %s", format)
+	}
+	return sb.String(), nil
+}
+
+// WriteCommentsToFile writes the comments to a file in the specified format (json, html, md, txt, or org).
+func WriteCommentsToFile(path string, comments []Comment, format string) error {
+	content, err := RenderComments(comments, format)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, content)
+}
+
+func writeCommentsHTML(sb *strings.Builder, comments []Comment) {
+	if len(comments) == 0 {
+		return
+	}
+	sb.WriteString("<ul>\n")
+	for _, c := range comments {
+		fmt.Fprintf(sb, "<li>\n<p><strong>%s</strong> <time>%s</time></p>\n", html.EscapeString(c.User.Name), html.EscapeString(c.Date))
+		for _, paragraph := range strings.Split(c.Body, "\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				fmt.Fprintf(sb, "<p>%s</p>\n", html.EscapeString(paragraph))
+			}
+		}
+		writeCommentsHTML(sb, c.Children)
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString("</ul>\n")
+}
+
+func writeCommentsMD(sb *strings.Builder, comments []Comment, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, c := range comments {
+		fmt.Fprintf(sb, "%s- **%s** (%s)\n", indent, c.User.Name, c.Date)
+		for _, paragraph := range strings.Split(c.Body, "\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				fmt.Fprintf(sb, "%s  %s\n", indent, paragraph)
+			}
+		}
+		writeCommentsMD(sb, c.Children, depth+1)
+	}
+}
+
+func writeCommentsText(sb *strings.Builder, comments []Comment, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, c := range comments {
+		fmt.Fprintf(sb, "%s%s (%s)\n", indent, c.User.Name, c.Date)
+		for _, paragraph := range strings.Split(c.Body, "\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				fmt.Fprintf(sb, "%s%s\n", indent, paragraph)
+			}
+		}
+		sb.WriteString("\n")
+		writeCommentsText(sb, c.Children, depth+1)
+	}
+}
+
+func writeCommentsOrg(sb *strings.Builder, comments []Comment, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, c := range comments {
+		fmt.Fprintf(sb, "%s- *%s* (%s)\n", indent, c.User.Name, c.Date)
+		for _, paragraph := range strings.Split(c.Body, "\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				fmt.Fprintf(sb, "%s  %s\n", indent, paragraph)
+			}
+		}
+		writeCommentsOrg(sb, c.Children, depth+1)
+	}
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// ExportedComment is a comment, without its replies, along with the post it belongs to.
+// It is the record written by CommentsExporter.
+type ExportedComment struct {
+	Comment
+	PostSlug string `json:"post_slug"`
+	PostUrl  string `json:"post_url"`
+	// ParentId is the id of the comment this one replies to, or 0 for the top-level comments.
+	ParentId int `json:"parent_id,omitempty"`
+}
+
+// CommentsExporter writes the comments of many posts to a single JSON Lines file, one comment per line,
+// so that the comments of a whole publication can be analyzed together.
+// The comments are streamed to the file as they are written, so memory usage doesn't grow with the archive.
+type CommentsExporter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewCommentsExporter opens the file at path for exporting comments.
+// If the file already exists, the comments are appended to it.
+func NewCommentsExporter(path string) (*CommentsExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &CommentsExporter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Write appends the comments of the post, and all their replies, to the export file.
+func (x *CommentsExporter) Write(post Post, comments []Comment) error {
+	enc := json.NewEncoder(x.w)
+	var write func(comments []Comment, parentId int) error
+	write = func(comments []Comment, parentId int) error {
+		for _, c := range comments {
+			children := c.Children
+			c.Children = nil
+			if c.PostId == 0 {
+				c.PostId = post.Id
+			}
+			record := ExportedComment{Comment: c, PostSlug: post.Slug, PostUrl: post.CanonicalUrl, ParentId: parentId}
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+			if err := write(children, c.Id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write(comments, 0); err != nil {
+		return err
+	}
+	return x.w.Flush()
+}
+
+// Close flushes the pending comments and closes the export file.
+func (x *CommentsExporter) Close() error {
+	if err := x.w.Flush(); err != nil {
+		x.f.Close()
+		return err
+	}
+	return x.f.Close()
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetPostCommentsOrder(t *testing.T) {
+	const total = 120
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		// the first pages answer last, to mix up the order of the concurrent fetches
+		time.Sleep(time.Duration(total-offset) * time.Millisecond / 4)
+		var res commentsResponse
+		if offset > 0 {
+			// a comment posted while fetching shifted the pages: the last comment of the previous page comes again
+			res.Comments = append(res.Comments, rawComment{Id: offset})
+		}
+		for id := offset + 1; id <= offset+commentsPageSize && id <= total; id++ {
+			res.Comments = append(res.Comments, rawComment{Id: id, Children: []rawComment{{Id: 1000 + id}}})
+		}
+		res.More = offset+commentsPageSize < total
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{"sequential", 1},
+		{"concurrent", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExtractor(NewFetcher(WithRatePerSecond(100)))
+			e.CommentsConcurrency = tt.concurrency
+			post := Post{Id: 1, CanonicalUrl: srv.URL + "/p/post", CommentCount: total + 10}
+			comments, err := e.GetPostComments(context.Background(), post)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(comments) != total {
+				t.Fatalf("got %d comments, want %d", len(comments), total)
+			}
+			for i, c := range comments {
+				if c.Id != i+1 {
+					t.Fatalf("comment %d has id %d, want %d", i, c.Id, i+1)
+				}
+				if len(c.Children) != 1 || c.Children[0].Id != 1000+c.Id {
+					t.Errorf("comment %d has the replies %+v", c.Id, c.Children)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrNoCover is returned by DownloadCover when the post has no cover image.
+var ErrNoCover = errors.New("the post has no cover image")
+
+// Cover is the cover image of a post, as listed in a gallery.
+type Cover struct {
+	Title   string
+	PostUrl string
+	Date    string
+	// Image is the path of the image file, relative to the gallery.
+	Image string
+}
+
+// DownloadCover downloads the cover image of the Post to the file at basePath, to which the extension of the image is added.
+// It returns the path of the written file, or ErrNoCover if the post has no cover image.
+func (e *Extractor) DownloadCover(ctx context.Context, p *Post, basePath string) (string, error) {
+	if p.CoverImage == "" {
+		return "", ErrNoCover
+	}
+	// some CDNs only serve the images to the pages of the publication;
+	// being third parties, they get no credentials from the Fetcher
+	res, err := e.fetcher.FetchURLFull(ctx, p.CoverImage, WithReferer(p.CanonicalUrl))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	filePath := basePath + imageExtension(p.CoverImage, res.Header.Get("Content-Type"), data)
+	return filePath, writeFile(filePath, string(data))
+}
+
+// AddCoverImage prepends the cover image of the Post to its body, unless the post has no cover image
+// or the body already shows it, e.g. as its first image. It reports whether the cover image was added.
+func (p *Post) AddCoverImage() bool {
+	if p.CoverImage == "" || p.hasImage(p.CoverImage) {
+		return false
+	}
+	p.BodyHTML = fmt.Sprintf("<figure class=\"cover\"><img src=\"%s\" alt=\"%s\"></figure>\n", html.EscapeString(p.CoverImage), html.EscapeString(p.Title)) + p.BodyHTML
+	return true
+}
+
+// hasImage reports whether the Post's body shows the image at imageUrl, either directly
+// or through the Substack CDN, whose URLs embed the escaped URL of the original image.
+func (p *Post) hasImage(imageUrl string) bool {
+	return strings.Contains(p.BodyHTML, imageUrl) ||
+		strings.Contains(p.BodyHTML, html.EscapeString(imageUrl)) ||
+		strings.Contains(p.BodyHTML, url.QueryEscape(imageUrl))
+}
+
+// imageExtension returns the file extension of the image at imageUrl: the one of its URL, if any,
+// or else the one of its content type, as reported by the server or detected from its data.
+func imageExtension(imageUrl string, contentType string, data []byte) string {
+	if u, err := url.Parse(imageUrl); err == nil {
+		switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif", ".svg":
+			return ext
+		}
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(mediaType) {
+	case "image/jpeg":
+		// mime.ExtensionsByType lists .jfif first on some systems
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	if exts, err := mime.ExtensionsByType(strings.TrimSpace(mediaType)); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".img"
+}
+
+// WriteCoverGallery writes an HTML page at path showing the covers as a grid of thumbnails,
+// each linking to its post.
+func WriteCoverGallery(path string, title string, covers []Cover) error {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n", html.EscapeString(title))
+	sb.WriteString("<style>\n" + coverGalleryStyle + "\n</style>\n")
+	sb.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n<div class=\"gallery\">\n", html.EscapeString(title))
+	for _, c := range covers {
+		fmt.Fprintf(&sb, "<figure><a href=\"%s\"><img src=\"%s\" alt=\"%s\" loading=\"lazy\"></a><figcaption>%s<br><small>%s</small></figcaption></figure>\n",
+			html.EscapeString(c.PostUrl), html.EscapeString(c.Image), html.EscapeString(c.Title), html.EscapeString(c.Title), html.EscapeString(c.Date))
+	}
+	sb.WriteString("</div>\n</body>\n</html>\n")
+	return writeFile(path, sb.String())
+}
+
+// coverGalleryStyle is the stylesheet of the cover gallery.
+const coverGalleryStyle = `body { margin: 2rem; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Arial, sans-serif; }
+.gallery { display: grid; grid-template-columns: repeat(auto-fill, minmax(200px, 1fr)); gap: 1rem; }
+figure { margin: 0; }
+img { width: 100%; aspect-ratio: 16 / 9; object-fit: cover; }
+figcaption { font-size: 0.9rem; }`
@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadCover(t *testing.T) {
+	var gotCredentials bool
+	srv := credentialServer(t, &gotCredentials)
+	e := NewExtractor(NewFetcher(WithCookie(&http.Cookie{Name: "substack.sid", Value: "secret"}), WithCookieHosts("localhost")))
+	dir := t.TempDir()
+
+	if _, err := e.DownloadCover(context.Background(), &Post{}, filepath.Join(dir, "none")); !errors.Is(err, ErrNoCover) {
+		t.Errorf("DownloadCover without a cover returned %v, want ErrNoCover", err)
+	}
+
+	p := &Post{CanonicalUrl: "http://localhost/p/post", CoverImage: srv.URL + "/cover.png"}
+	path, err := e.DownloadCover(context.Background(), p, filepath.Join(dir, "post.cover"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotCredentials {
+		t.Error("DownloadCover sent the credentials to the image host")
+	}
+	if filepath.Dir(path) != dir {
+		t.Errorf("DownloadCover wrote %s, outside of %s", path, dir)
+	}
+}
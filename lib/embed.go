@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// selfContainedStyle is the stylesheet of self-contained HTML documents.
+// It only relies on the fonts available on the system, so that the document has no external dependencies.
+const selfContainedStyle = `body { max-width: 42rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.6;
+  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif; }
+pre, code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+img { max-width: 100%; height: auto; }
+figure { margin: 1.5rem 0; }`
+
+// EmbedImages replaces the URLs of the images in the Post's body with data URIs, so that the body has no external dependencies.
+// The responsive variants (srcset attributes and <source> elements) and the image data attributes of the Substack widgets
+// are removed, since the browser would load them instead of the embedded image.
+// Images that cannot be fetched keep their remote URL.
+func (e *Extractor) EmbedImages(ctx context.Context, p *Post) error {
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return err
+	}
+	body := doc.Find("body")
+
+	body.Find("picture source").Remove()
+	body.Find("[srcset]").RemoveAttr("srcset").RemoveAttr("sizes")
+	body.Find(".captioned-image-container [data-attrs], .image-link [data-attrs], .image-gallery-embed[data-attrs]").RemoveAttr("data-attrs")
+
+	dataURIs := make(map[string]string)
+	body.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		src := s.AttrOr("src", "")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		dataURI, ok := dataURIs[src]
+		if !ok {
+			var fetchErr error
+			dataURI, fetchErr = e.fetchDataURI(ctx, src, p.CanonicalUrl)
+			if fetchErr != nil {
+				return
+			}
+			dataURIs[src] = dataURI
+		}
+		s.SetAttr("src", dataURI)
+	})
+
+	p.BodyHTML, err = body.Html()
+	return err
+}
+
+// fetchDataURI fetches the resource at url, used in the page at referer, and returns it as a base64 data URI.
+// The images are usually served by CDNs and other third parties, which get no credentials from the Fetcher.
+func (e *Extractor) fetchDataURI(ctx context.Context, url string, referer string) (string, error) {
+	data, mediaType, err := e.FetchImage(ctx, url, referer)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ImageFetcher returns the content and the media type of the image at src, used in the page at referer,
+// e.g. to embed it in an EPUB book.
+type ImageFetcher func(src string, referer string) (data []byte, mediaType string, err error)
+
+// FetchImage fetches the image at src, used in the page at referer, and returns its content and media type,
+// as reported by the server or else detected from the content.
+func (e *Extractor) FetchImage(ctx context.Context, src string, referer string) ([]byte, string, error) {
+	res, err := e.fetcher.FetchURLFull(ctx, src, WithReferer(referer))
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	mediaType, _, _ := strings.Cut(res.Header.Get("Content-Type"), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return data, mediaType, nil
+}
@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmbedImagesSendsNoCredentialsToImageHosts(t *testing.T) {
+	var gotCredentials bool
+	srv := credentialServer(t, &gotCredentials)
+	e := NewExtractor(NewFetcher(WithCookie(&http.Cookie{Name: "substack.sid", Value: "secret"}), WithCookieHosts("localhost")))
+	p := &Post{
+		CanonicalUrl: "http://localhost/p/post",
+		BodyHTML:     `<p><img src="` + srv.URL + `/image.png"></p><p><img src="` + srv.URL + `/image.png"></p>`,
+	}
+
+	if err := e.EmbedImages(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+	if gotCredentials {
+		t.Error("EmbedImages sent the credentials to the image host")
+	}
+	if strings.Count(p.BodyHTML, `src="data:`) != 2 {
+		t.Errorf("EmbedImages didn't embed the images: %s", p.BodyHTML)
+	}
+}
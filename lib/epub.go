@@ -0,0 +1,389 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	nethtml "golang.org/x/net/html"
+)
+
+// EPUBBook is an EPUB 3 book made of posts, one chapter each, with a table of contents.
+type EPUBBook struct {
+	// Identifier uniquely identifies the book, e.g. the URL of the post or of the publication.
+	Identifier string
+	Title      string
+	Author     string
+	// Language is the language of the book, as a BCP 47 tag. It defaults to "en".
+	Language string
+	// Modified is the last modification time of the book. It defaults to the time the book is built.
+	Modified time.Time
+
+	chapters []epubChapter
+	images   []epubImage
+	// imageFiles holds the files of the embedded images, by URL, or an empty string for the images which could not be fetched.
+	imageFiles map[string]string
+}
+
+// epubChapter is a chapter of an EPUBBook, with its XHTML body.
+type epubChapter struct {
+	title string
+	body  string
+	// remote reports whether the body references remote resources, e.g. the images which could not be embedded.
+	remote bool
+}
+
+// epubImage is an image embedded in an EPUBBook.
+type epubImage struct {
+	file      string
+	mediaType string
+	data      []byte
+}
+
+// epubImageExtensions lists the extensions of the image media types which e-readers are required to support.
+var epubImageExtensions = map[string]string{
+	"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif", "image/webp": ".webp", "image/svg+xml": ".svg",
+}
+
+// xmlNameRegex matches the attribute names which are valid in XHTML.
+var xmlNameRegex = regexp.MustCompile(`^[a-zA-Z_][-a-zA-Z0-9_.]*$`)
+
+// xhtmlVoidElements lists the elements which have no content, written as self-closing tags in XHTML.
+var xhtmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// xhtmlDroppedElements lists the elements left out of the chapters, along with their content,
+// since e-readers don't run scripts nor load embedded pages.
+var xhtmlDroppedElements = map[string]bool{
+	"script": true, "noscript": true, "iframe": true, "form": true,
+}
+
+// AddPost adds the post as a chapter of the book, rendered as in the html format with the options,
+// e.g. WithComments or WithTranscript, and its images are embedded in the book with WithImages.
+// Its title falls back to its slug when missing.
+func (b *EPUBBook) AddPost(p *Post, opts ...WriteOption) error {
+	var o WriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return b.addPost(p, o)
+}
+
+// addPost adds the post as a chapter of the book, rendered as in the html format with the options.
+func (b *EPUBBook) addPost(p *Post, o WriteOptions) error {
+	// the book is a document of its own
+	o.FullHTML, o.BaseHref, o.SelfContained, o.EReader = false, "", false, false
+
+	title := p.bookTitle()
+	post := *p
+	post.Title = title
+	content, err := post.contentForFormat("html", o)
+	if err != nil {
+		return err
+	}
+	content, remote, err := b.embedImages(content, p.CanonicalUrl, o.Images)
+	if err != nil {
+		return err
+	}
+	body, err := toXHTML(content)
+	if err != nil {
+		return err
+	}
+	b.chapters = append(b.chapters, epubChapter{title: title, body: body, remote: remote})
+	return nil
+}
+
+// embedImages fetches the images of the chapter content, used in the page at referer, with fetch, if not nil,
+// adds them to the book, and points the content to them. The responsive variants are removed, since the e-readers would load them instead.
+// It reports whether the content still references remote resources, e.g. the images which could not be fetched.
+func (b *EPUBBook) embedImages(content string, referer string, fetch ImageFetcher) (string, bool, error) {
+	doc, err := parseHTML(content)
+	if err != nil {
+		return "", false, err
+	}
+	body := doc.Find("body")
+	body.Find("picture source").Remove()
+	body.Find("[srcset]").RemoveAttr("srcset").RemoveAttr("sizes")
+
+	if b.imageFiles == nil {
+		b.imageFiles = make(map[string]string)
+	}
+	body.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		src := s.AttrOr("src", "")
+		if !isRemote(src) {
+			return
+		}
+		file, fetched := b.imageFiles[src]
+		if !fetched && fetch != nil {
+			data, mediaType, err := fetch(src, referer)
+			if ext, ok := epubImageExtensions[mediaType]; err == nil && ok {
+				file = fmt.Sprintf("images/image-%d%s", len(b.images)+1, ext)
+				b.images = append(b.images, epubImage{file: file, mediaType: mediaType, data: data})
+			}
+			b.imageFiles[src] = file
+		}
+		if file != "" {
+			s.SetAttr("src", file)
+		}
+	})
+
+	remote := false
+	body.Find("[src]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		remote = isRemote(s.AttrOr("src", ""))
+		return !remote
+	})
+	content, err = body.Html()
+	return content, remote, err
+}
+
+// isRemote reports whether the URL references a remote resource.
+func isRemote(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "//")
+}
+
+// Len returns the number of chapters of the book.
+func (b *EPUBBook) Len() int {
+	return len(b.chapters)
+}
+
+// Bytes returns the book packaged as an EPUB file.
+func (b *EPUBBook) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// the mimetype must be the first file of the container, and not compressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+
+	files := []struct{ name, content string }{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", b.packageDocument()},
+		{"OEBPS/nav.xhtml", b.navDocument()},
+		{"OEBPS/style.css", ereaderStyle},
+	}
+	for i, c := range b.chapters {
+		files = append(files, struct{ name, content string }{"OEBPS/" + chapterFile(i), b.chapterDocument(c)})
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			return nil, err
+		}
+	}
+	for _, img := range b.images {
+		w, err := zw.Create("OEBPS/" + img.file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(img.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteToFile writes the book to an EPUB file.
+func (b *EPUBBook) WriteToFile(path string) error {
+	content, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+	return writeFile(path, string(content))
+}
+
+// ToEPUB returns the Post as an EPUB book with a single chapter.
+// The options apply to the chapter as in the html format, e.g. WithComments or WithTranscript.
+func (p *Post) ToEPUB(opts ...WriteOption) ([]byte, error) {
+	var o WriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return p.toEPUB(o)
+}
+
+// toEPUB returns the Post as an EPUB book with a single chapter, rendered with the options.
+func (p *Post) toEPUB(o WriteOptions) ([]byte, error) {
+	book := EPUBBook{
+		Identifier: p.CanonicalUrl,
+		Title:      p.bookTitle(),
+		Author:     p.Author(),
+	}
+	if postDate, err := time.Parse(time.RFC3339, p.PostDate); err == nil {
+		book.Modified = postDate
+	}
+	if err := book.addPost(p, o); err != nil {
+		return nil, err
+	}
+	return book.Bytes()
+}
+
+// bookTitle returns the title of the Post, or its slug if it has none.
+func (p *Post) bookTitle() string {
+	if strings.TrimSpace(p.Title) != "" {
+		return p.Title
+	}
+	return p.Slug
+}
+
+// chapterFile returns the name of the file of the i-th chapter in the container.
+func chapterFile(i int) string {
+	return fmt.Sprintf("chapter-%d.xhtml", i+1)
+}
+
+// epubContainer is the container file pointing to the package document.
+const epubContainer = `<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// language returns the language of the book, which defaults to English.
+func (b *EPUBBook) language() string {
+	if b.Language == "" {
+		return "en"
+	}
+	return b.Language
+}
+
+// packageDocument returns the package document of the book, with its metadata, manifest and spine.
+func (b *EPUBBook) packageDocument() string {
+	modified := b.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	identifier := b.Identifier
+	if identifier == "" {
+		identifier = b.Title
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	fmt.Fprintf(&sb, `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%s">`+"\n", html.EscapeString(b.language()))
+	sb.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&sb, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", html.EscapeString(identifier))
+	fmt.Fprintf(&sb, "    <dc:title>%s</dc:title>\n", html.EscapeString(b.Title))
+	fmt.Fprintf(&sb, "    <dc:language>%s</dc:language>\n", html.EscapeString(b.language()))
+	if b.Author != "" {
+		fmt.Fprintf(&sb, "    <dc:creator>%s</dc:creator>\n", html.EscapeString(b.Author))
+	}
+	fmt.Fprintf(&sb, "    <meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	sb.WriteString("  </metadata>\n  <manifest>\n")
+	sb.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	sb.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for i, c := range b.chapters {
+		properties := ""
+		if c.remote {
+			properties = ` properties="remote-resources"`
+		}
+		fmt.Fprintf(&sb, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"%s/>\n", i+1, chapterFile(i), properties)
+	}
+	for i, img := range b.images {
+		fmt.Fprintf(&sb, "    <item id=\"image-%d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, img.file, img.mediaType)
+	}
+	sb.WriteString("  </manifest>\n  <spine>\n")
+	for i := range b.chapters {
+		fmt.Fprintf(&sb, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	sb.WriteString("  </spine>\n</package>\n")
+	return sb.String()
+}
+
+// navDocument returns the navigation document of the book, listing its chapters.
+func (b *EPUBBook) navDocument() string {
+	var sb strings.Builder
+	sb.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for i, c := range b.chapters {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a></li>\n", chapterFile(i), html.EscapeString(c.title))
+	}
+	sb.WriteString("</ol>\n</nav>")
+	return b.xhtmlDocument(b.Title, sb.String())
+}
+
+// chapterDocument returns the XHTML document of the chapter.
+func (b *EPUBBook) chapterDocument(c epubChapter) string {
+	return b.xhtmlDocument(c.title, c.body)
+}
+
+// xhtmlDocument wraps the XHTML body in a complete XHTML document of the book.
+func (b *EPUBBook) xhtmlDocument(title string, body string) string {
+	lang := html.EscapeString(b.language())
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%s" xml:lang="%s">
+<head>
+<title>%s</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+%s
+</body>
+</html>
+`, lang, lang, html.EscapeString(title), body)
+}
+
+// toXHTML converts the HTML fragment to XHTML, as required by EPUB: the void elements are self-closed,
+// the attributes with a name which is not valid in XML are dropped, and so are scripts and embedded pages.
+func toXHTML(fragment string) (string, error) {
+	doc, err := parseHTML(fragment)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, n := range doc.Find("body").Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeXHTML(&sb, c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// writeXHTML writes the node, and all its children, as XHTML.
+func writeXHTML(sb *strings.Builder, n *nethtml.Node) {
+	switch n.Type {
+	case nethtml.TextNode:
+		sb.WriteString(html.EscapeString(n.Data))
+	case nethtml.ElementNode:
+		if xhtmlDroppedElements[n.Data] {
+			return
+		}
+		sb.WriteString("<" + n.Data)
+		seen := make(map[string]bool)
+		for _, attr := range n.Attr {
+			if attr.Namespace != "" || !xmlNameRegex.MatchString(attr.Key) || seen[attr.Key] {
+				continue
+			}
+			seen[attr.Key] = true
+			fmt.Fprintf(sb, " %s=\"%s\"", attr.Key, html.EscapeString(attr.Val))
+		}
+		if xhtmlVoidElements[n.Data] {
+			sb.WriteString("/>")
+			return
+		}
+		sb.WriteString(">")
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeXHTML(sb, c)
+		}
+		sb.WriteString("</" + n.Data + ">")
+	}
+}
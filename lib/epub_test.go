@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readEPUB returns the files of the EPUB book, by name.
+func readEPUB(t *testing.T, book []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func TestEPUBBookImages(t *testing.T) {
+	fetch := func(src string, referer string) ([]byte, string, error) {
+		switch src {
+		case "https://cdn.example.com/a.png":
+			return []byte("png"), "image/png", nil
+		case "https://cdn.example.com/page.html":
+			return []byte("<html>"), "text/html", nil
+		}
+		return nil, "", errors.New("not found")
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		fetch      ImageFetcher
+		wantImages int
+		wantRemote bool
+	}{
+		{"embedded", `<p><img src="https://cdn.example.com/a.png"></p><p><img src="https://cdn.example.com/a.png" srcset="https://cdn.example.com/a2.png 2x"></p>`, fetch, 1, false},
+		{"fetch failure", `<p><img src="https://cdn.example.com/missing.png"></p>`, fetch, 0, true},
+		{"not an image", `<p><img src="https://cdn.example.com/page.html"></p>`, fetch, 0, true},
+		{"no fetcher", `<p><img src="https://cdn.example.com/a.png"></p>`, nil, 0, true},
+		{"no image", `<p>Text</p>`, fetch, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := EPUBBook{Title: "Book"}
+			if err := book.AddPost(&Post{Title: "Post", BodyHTML: tt.body}, WithImages(tt.fetch)); err != nil {
+				t.Fatal(err)
+			}
+			b, err := book.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := readEPUB(t, b)
+
+			opf := files["OEBPS/content.opf"]
+			if got := strings.Count(opf, `media-type="image/`); got != tt.wantImages {
+				t.Errorf("the manifest lists %d images, want %d:\n%s", got, tt.wantImages, opf)
+			}
+			if got := strings.Contains(opf, `properties="remote-resources"`); got != tt.wantRemote {
+				t.Errorf("remote-resources declared = %v, want %v:\n%s", got, tt.wantRemote, opf)
+			}
+			chapter := files["OEBPS/chapter-1.xhtml"]
+			if strings.Contains(chapter, "srcset") {
+				t.Errorf("the chapter keeps the responsive variants:\n%s", chapter)
+			}
+			if tt.wantImages > 0 {
+				if files["OEBPS/images/image-1.png"] != "png" {
+					t.Error("the image is not embedded in the book")
+				}
+				if strings.Contains(chapter, "https://cdn.example.com/a.png") || !strings.Contains(chapter, `src="images/image-1.png"`) {
+					t.Errorf("the chapter doesn't point to the embedded image:\n%s", chapter)
+				}
+			}
+		})
+	}
+}
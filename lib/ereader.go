@@ -0,0 +1,22 @@
+package lib
+
+// ereaderStyle is the stylesheet of the HTML documents written for e-readers:
+// serif fonts, large margins, and no fixed widths, so that the reader can reflow the text to its screen and settings.
+const ereaderStyle = `body { margin: 5%; line-height: 1.5; font-family: Georgia, "Times New Roman", serif; }
+h1, h2, h3, h4, h5, h6 { line-height: 1.2; page-break-after: avoid; }
+p { margin: 0 0 1em; text-align: justify; hyphens: auto; }
+blockquote { margin: 1em 5%; font-style: italic; }
+pre { white-space: pre-wrap; }
+pre, code { font-family: "Courier New", monospace; font-size: 0.9em; }
+img { max-width: 100%; height: auto; }
+figure { margin: 1em 0; text-align: center; }
+figcaption { font-size: 0.9em; font-style: italic; }`
+
+// WithEReader makes the html format a complete HTML document styled for e-readers.
+// Combined with EReaderHTML, the post only keeps its prose and images, without widgets.
+func WithEReader() WriteOption {
+	return func(o *WriteOptions) {
+		o.FullHTML = true
+		o.EReader = true
+	}
+}
@@ -1,15 +1,22 @@
 package lib
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/PuerkitoBio/goquery"
@@ -19,6 +26,10 @@ import (
 // RawPost represents a raw Substack post in string format.
 type RawPost struct {
 	str string
+	// page is the page the data was extracted from, used as a fallback for the body.
+	page *goquery.Document
+	// cleanup describes how the page was cleaned up to decode the data, if it was malformed.
+	cleanup string
 }
 
 // ToPost converts the RawPost to a structured Post object.
@@ -39,15 +50,145 @@ type Post struct {
 	Type             string `json:"type"`
 	Slug             string `json:"slug"`
 	PostDate         string `json:"post_date"`
+	UpdatedAt        string `json:"updated_at,omitempty"`
 	CanonicalUrl     string `json:"canonical_url"`
 	PreviousPostSlug string `json:"previous_post_slug"`
 	NextPostSlug     string `json:"next_post_slug"`
 	CoverImage       string `json:"cover_image"`
 	Description      string `json:"description"`
 	WordCount        int    `json:"wordcount"`
-	//PostTags         []string `json:"postTags"`
-	Title    string `json:"title"`
-	BodyHTML string `json:"body_html"`
+	// Audience is who the post is for: "everyone", "only_free", "only_paid" or "founding".
+	Audience string `json:"audience,omitempty"`
+	// ShouldShowPaywall is true when the page was served with a paywall: the body is only a preview of the post.
+	ShouldShowPaywall bool `json:"should_show_paywall,omitempty"`
+	// PostTags lists the tags of the post, as set by its authors.
+	PostTags      []PostTag      `json:"postTags,omitempty"`
+	Title         string         `json:"title"`
+	BodyHTML      string         `json:"body_html"`
+	ReactionCount int            `json:"reaction_count"`
+	Reactions     map[string]int `json:"reactions,omitempty"`
+	CommentCount  int            `json:"comment_count"`
+	Polls         []Poll         `json:"polls,omitempty"`
+	EmailBodyHTML string         `json:"email_body,omitempty"`
+	Transcript    Transcript     `json:"transcript,omitempty"`
+	// PodcastUrl is the URL of the audio file of podcast posts.
+	PodcastUrl string `json:"podcast_url,omitempty"`
+	// PodcastEpisode holds the episode data of podcast posts, which can include the transcript too.
+	PodcastEpisode *struct {
+		Transcript Transcript `json:"transcript"`
+	} `json:"podcast_episode,omitempty"`
+	// PublishedBylines lists the authors of the post.
+	PublishedBylines []Byline `json:"publishedBylines,omitempty"`
+
+	// alternateUrls are the URLs, other than the canonical one, the post was requested or redirected from.
+	alternateUrls []string
+	// requestedUrl is the URL the post was requested from.
+	requestedUrl string
+	// raw is the JSON data the post was extracted from, if any.
+	raw string
+	// pageCleanup describes how the page of the post was cleaned up to extract it, if it was malformed.
+	pageCleanup string
+}
+
+// Byline is an author of a post.
+type Byline struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Handle string `json:"handle"`
+}
+
+// Author returns the names of the authors of the Post, separated by commas, or an empty string if unknown.
+func (p *Post) Author() string {
+	var names []string
+	for _, b := range p.PublishedBylines {
+		if b.Name != "" {
+			names = append(names, b.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// PostTag is a tag of a post.
+type PostTag struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// Tags returns the names of the tags of the Post.
+func (p *Post) Tags() []string {
+	var names []string
+	for _, t := range p.PostTags {
+		if t.Name != "" {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// HasTag reports whether the Post has any of the tags, matched case-insensitively against the name or the slug of its tags.
+func (p *Post) HasTag(tags ...string) bool {
+	for _, t := range p.PostTags {
+		for _, tag := range tags {
+			if strings.EqualFold(tag, t.Name) || strings.EqualFold(tag, t.Slug) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UseEmailBody replaces the Post's HTML body with the body of the email sent to the subscribers, if available.
+// It reports whether the body was replaced.
+func (p *Post) UseEmailBody() bool {
+	if strings.TrimSpace(p.EmailBodyHTML) == "" {
+		return false
+	}
+	p.BodyHTML = p.EmailBodyHTML
+	return true
+}
+
+// UseRequestedURL makes the URL the Post was requested from its canonical URL, and derives the slug from it,
+// e.g. to name the file after a specific share link. The former canonical URL is kept among the aliases.
+// It reports whether the canonical URL changed.
+func (p *Post) UseRequestedURL() bool {
+	requestedUrl := normalizeURL(p.requestedUrl)
+	if requestedUrl == "" || requestedUrl == p.CanonicalUrl {
+		return false
+	}
+	alternateUrls := []string{p.CanonicalUrl}
+	for _, u := range p.alternateUrls {
+		if u != p.requestedUrl {
+			alternateUrls = append(alternateUrls, u)
+		}
+	}
+	p.alternateUrls = alternateUrls
+	p.CanonicalUrl = requestedUrl
+	p.Slug = slugFromURL(requestedUrl)
+	return true
+}
+
+// AssetURLs returns the URLs of the images and of the files attached to the Post's body, without duplicates.
+func (p *Post) AssetURLs() ([]string, error) {
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(u string) {
+		if u != "" && !seen[u] && (strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")) {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""))
+	})
+	doc.Find("a[href*='/api/v1/file/']").Each(func(i int, s *goquery.Selection) {
+		add(s.AttrOr("href", ""))
+	})
+	return urls, nil
 }
 
 // ToMD converts the Post's HTML body to Markdown format.
@@ -56,14 +197,64 @@ func (p *Post) ToMD(withTitle bool) (string, error) {
 	if withTitle {
 		title = fmt.Sprintf("# %s\n\n", p.Title)
 	}
+	bodyHTML, err := fillImageAltText(p.BodyHTML)
+	if err != nil {
+		return "", err
+	}
 	converter := md.NewConverter("", true, nil)
-	body, err := converter.ConvertString(p.BodyHTML)
+	body, err := converter.ConvertString(bodyHTML)
 	if err != nil {
 		return "", err
 	}
 	return title + body, nil
 }
 
+// imageDimensionsRegex matches the dimensions suffix of the images uploaded to Substack, e.g. "_1024x768".
+var imageDimensionsRegex = regexp.MustCompile(`_\d+x\d+$`)
+
+// fillImageAltText gives a description to the images of the HTML body without alt text,
+// so that they don't end up with an empty description in Markdown.
+// The description is the caption of the image figure, if any, or is derived from the image filename.
+func fillImageAltText(bodyHTML string) (string, error) {
+	doc, err := parseHTML(bodyHTML)
+	if err != nil {
+		return "", err
+	}
+
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if alt, _ := s.Attr("alt"); strings.TrimSpace(alt) != "" {
+			return
+		}
+		alt := strings.TrimSpace(s.Closest("figure").Find("figcaption").First().Text())
+		if alt == "" {
+			src, _ := s.Attr("src")
+			alt = altTextFromURL(src)
+		}
+		if alt != "" {
+			s.SetAttr("alt", alt)
+		}
+	})
+
+	return doc.Find("body").Html()
+}
+
+// altTextFromURL derives a description of an image from its filename,
+// e.g. https://example.com/images/my-cat_1024x768.jpg -> my cat
+func altTextFromURL(src string) string {
+	if unescaped, err := url.PathUnescape(src); err == nil {
+		src = unescaped
+	}
+	src, _, _ = strings.Cut(src, "?")
+	name := path.Base(src)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = imageDimensionsRegex.ReplaceAllString(name, "")
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
 // ToText converts the Post's HTML body to plain text format.
 func (p *Post) ToText(withTitle bool) string {
 	if withTitle {
@@ -89,32 +280,309 @@ func (p *Post) ToJSON() (string, error) {
 	return string(b), nil
 }
 
-// WriteToFile writes the Post's content to a file in the specified format (html, md, or txt).
-func (p *Post) WriteToFile(path string, format string) error {
-	err := os.MkdirAll(filepath.Dir(path), 0755)
-	if err != nil {
-		return err
+// ToFullHTML returns the Post as a complete HTML document, using its title as both document title and header.
+// If baseHref is not empty, a <base> element is added so that relative URLs are resolved against it.
+func (p *Post) ToFullHTML(baseHref string) string {
+	return p.htmlDocument(p.ToHTML(true), WriteOptions{FullHTML: true, BaseHref: baseHref})
+}
+
+// htmlDocument wraps the HTML body in a complete HTML document, according to the options.
+func (p *Post) htmlDocument(body string, o WriteOptions) string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	if o.BaseHref != "" {
+		fmt.Fprintf(&sb, "<base href=\"%s\">\n", html.EscapeString(o.BaseHref))
 	}
-	f, err := os.Create(path)
-	if err != nil {
-		return err
+	fmt.Fprintf(&sb, "<title>%s</title>\n", html.EscapeString(p.Title))
+	if o.EReader {
+		fmt.Fprintf(&sb, "<style>\n%s\n</style>\n", ereaderStyle)
+	} else if o.SelfContained {
+		fmt.Fprintf(&sb, "<style>\n%s\n</style>\n", selfContainedStyle)
 	}
-	defer f.Close()
+	sb.WriteString("</head>\n<body>\n")
+	sb.WriteString(body)
+	sb.WriteString("\n</body>\n</html>\n")
+	return sb.String()
+}
+
+// WriteOptions holds configurable options for writing a Post to a file.
+type WriteOptions struct {
+	// FullHTML makes the html format a complete HTML document instead of a fragment.
+	FullHTML bool
+	// BaseHref adds a <base> element to the html format, which is then always a complete HTML document.
+	BaseHref string
+	// SelfContained makes the html format a complete HTML document with an inline stylesheet using system fonts.
+	SelfContained bool
+	// EReader makes the html format a complete HTML document with a stylesheet for e-readers.
+	EReader bool
+	// Comments are appended to the post, rendered in the same format.
+	Comments []Comment
+	// FrontMatter prepends the post metadata as YAML front matter to the md format.
+	FrontMatter bool
+	// FrontMatterPreset selects the fields of the front matter.
+	FrontMatterPreset FrontMatterPreset
+	// PreserveModTime sets the modification time of the file to the post date.
+	PreserveModTime bool
+	// Transcript appends the transcript of podcast posts, if any, rendered in the same format.
+	Transcript bool
+	// Gzip compresses the file with gzip, adding the .gz extension to its path.
+	Gzip bool
+	// LinkReferences renders the links of the txt format as numbered references listed at the end of the post.
+	LinkReferences bool
+	// Images, if not nil, fetches the images of the epub format, which are then embedded in the book.
+	Images ImageFetcher
+}
+
+// WriteOption defines a function that applies a specific option to WriteOptions.
+type WriteOption func(*WriteOptions)
+
+// WithImages embeds the images of the epub format in the book, fetched with fetch, e.g. with Extractor.FetchImage.
+// The images which cannot be fetched, or without the option, are left as remote resources.
+func WithImages(fetch ImageFetcher) WriteOption {
+	return func(o *WriteOptions) {
+		o.Images = fetch
+	}
+}
+
+// WithFullHTML makes the html format a complete HTML document instead of a fragment.
+func WithFullHTML() WriteOption {
+	return func(o *WriteOptions) {
+		o.FullHTML = true
+	}
+}
+
+// WithSelfContained makes the html format a complete HTML document with an inline stylesheet using system fonts.
+// Combined with Extractor.EmbedImages, the document has no external dependencies.
+func WithSelfContained() WriteOption {
+	return func(o *WriteOptions) {
+		o.FullHTML = true
+		o.SelfContained = true
+	}
+}
+
+// WithBaseHref adds a <base> element with the given URL to the html format.
+func WithBaseHref(href string) WriteOption {
+	return func(o *WriteOptions) {
+		o.BaseHref = href
+	}
+}
+
+// WithFrontMatter prepends the post metadata as YAML front matter to the md format.
+func WithFrontMatter() WriteOption {
+	return func(o *WriteOptions) {
+		o.FrontMatter = true
+	}
+}
+
+// WithPreserveModTime sets the modification time of the written file to the post date,
+// so that the files of an archive sort chronologically. Posts with an unparseable date keep the current time.
+func WithPreserveModTime() WriteOption {
+	return func(o *WriteOptions) {
+		o.PreserveModTime = true
+	}
+}
+
+// WithLinkReferences renders the links of the txt format as numbered references listed at the end of the post,
+// instead of dropping their targets.
+func WithLinkReferences() WriteOption {
+	return func(o *WriteOptions) {
+		o.LinkReferences = true
+	}
+}
+
+// WithFrontMatterPreset prepends the post metadata as YAML front matter to the md format,
+// with the fields expected by the static site generator of the preset.
+func WithFrontMatterPreset(preset FrontMatterPreset) WriteOption {
+	return func(o *WriteOptions) {
+		o.FrontMatter = true
+		o.FrontMatterPreset = preset
+	}
+}
+
+// WithTranscript appends the transcript of podcast posts, if any, rendered in the same format.
+func WithTranscript() WriteOption {
+	return func(o *WriteOptions) {
+		o.Transcript = true
+	}
+}
+
+// WithGzip compresses the file with gzip, adding the .gz extension to its path (e.g. post.html.gz).
+func WithGzip() WriteOption {
+	return func(o *WriteOptions) {
+		o.Gzip = true
+	}
+}
+
+// WithComments appends the comments to the post, rendered in the same format.
+func WithComments(comments []Comment) WriteOption {
+	return func(o *WriteOptions) {
+		o.Comments = comments
+	}
+}
+
+// contentForFormat returns the Post's content rendered in the specified format (html, md, txt, or org).
+func (p *Post) contentForFormat(format string, o WriteOptions) (string, error) {
 	var content string
+	var err error
 	switch format {
 	case "html":
 		content = p.ToHTML(true)
 	case "md":
 		content, err = p.ToMD(true)
 		if err != nil {
-			return err
+			return "", err
 		}
 	case "txt":
-		content = p.ToText(true)
+		if o.LinkReferences {
+			content, err = p.ToTextWithReferences(true)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			content = p.ToText(true)
+		}
+	case "org":
+		content, err = p.ToOrg(true)
+		if err != nil {
+			return "", err
+		}
+	case "epub":
+		// the book is binary: it is built from the html format, transcript and comments included
+		b, err := p.toEPUB(o)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
 	default:
-		return fmt.Errorf("unknown format: %s", format)
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+
+	if o.Transcript && len(p.Transcript) > 0 {
+		content += "\n\n" + p.Transcript.render(format)
+	}
+
+	if o.Comments != nil {
+		renderedComments, err := RenderComments(o.Comments, format)
+		if err != nil {
+			return "", err
+		}
+		content += "\n\n" + renderedComments
 	}
-	_, err = f.WriteString(content)
+
+	if format == "md" && o.FrontMatter {
+		content = p.FrontMatterFor(o.FrontMatterPreset) + content
+	}
+
+	if format == "html" && (o.FullHTML || o.BaseHref != "") {
+		content = p.htmlDocument(content, o)
+	}
+
+	return content, nil
+}
+
+// WriteToFile writes the Post's content to a file in the specified format (html, md, txt, org, or epub).
+func (p *Post) WriteToFile(path string, format string, opts ...WriteOption) error {
+	var o WriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	content, err := p.contentForFormat(format, o)
+	if err != nil {
+		return err
+	}
+	if o.Gzip {
+		path += ".gz"
+		content, err = gzipString(content)
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeFile(path, content); err != nil {
+		return err
+	}
+	if o.PreserveModTime {
+		postDate, err := time.Parse(time.RFC3339, p.PostDate)
+		if err != nil {
+			return nil
+		}
+		return os.Chtimes(path, postDate, postDate)
+	}
+	return nil
+}
+
+// WriteToFileWithComments writes the Post's content to a file in the specified format (html, md, txt, or org),
+// followed by its comments rendered in the same format.
+func (p *Post) WriteToFileWithComments(path string, format string, comments []Comment, opts ...WriteOption) error {
+	return p.WriteToFile(path, format, append(opts, WithComments(comments))...)
+}
+
+// PageCleanup describes how the page the Post was extracted from was cleaned up, e.g. "control characters removed",
+// when it was too malformed to be extracted as is. It is empty for the well-formed pages.
+func (p *Post) PageCleanup() string {
+	return p.pageCleanup
+}
+
+// RawJSON returns the JSON data of the page the Post was extracted from, before any conversion.
+// It is empty if the Post was not extracted from a page, or by an Extractor without KeepRaw.
+func (p *Post) RawJSON() string {
+	return p.raw
+}
+
+// WriteRawToFile writes the JSON data of the page the Post was extracted from to a file,
+// so that it can be processed again later, e.g. by a newer version of the extraction logic.
+func (p *Post) WriteRawToFile(path string) error {
+	if p.raw == "" {
+		return errors.New("no raw data available")
+	}
+	return writeFile(path, p.raw)
+}
+
+// gzipString returns the content compressed with gzip.
+func gzipString(content string) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeFile writes content to the file at path, creating any missing parent directory.
+// The content is written to a temporary file first, which is then renamed to path:
+// this way, an interrupted write never leaves a truncated file behind.
+func writeFile(path string, content string) error {
+	return writeFileFrom(path, strings.NewReader(content))
+}
+
+// WriteFile writes content to the file at path the way the posts are written: to a temporary file renamed to path,
+// so that an interrupted write never leaves a truncated file behind, e.g. for the state of a run.
+func WriteFile(path string, content []byte) error {
+	return writeFileFrom(path, bytes.NewReader(content))
+}
+
+// writeFileFrom works like writeFile, but it streams the content from r, e.g. to write large downloads.
+func writeFileFrom(path string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = f.Chmod(0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
 	if err != nil {
 		return err
 	}
@@ -124,7 +592,12 @@ func (p *Post) WriteToFile(path string, format string) error {
 		return err
 	}
 
-	return nil
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
 }
 
 // PostWrapper wraps a Post object for JSON unmarshaling.
@@ -135,6 +608,35 @@ type PostWrapper struct {
 // Extractor is a utility for extracting Substack posts from URLs.
 type Extractor struct {
 	fetcher *Fetcher
+
+	// CommentsConcurrency is the maximum number of comment pages fetched at the same time.
+	// Values lower than 2 disable concurrent fetching.
+	CommentsConcurrency int
+
+	// Workers is the maximum number of posts extracted at the same time by ExtractAllPosts.
+	// If it is not positive, DefaultWorkers is used.
+	Workers int
+
+	// BodySelector is the CSS selector of the post content in the page, used as the body
+	// when the page data has none, as it happens with some nonstandard publications.
+	// It is a best-effort fallback: if it is empty, or nothing matches it, the body is left empty.
+	BodySelector string
+
+	// ListingCache, if not nil, stores the archive listings fetched by GetAllPostsURLs for reuse.
+	ListingCache *ListingCache
+
+	// UseArchiveAPI makes GetAllPostsURLs also list the posts through the archive API of the publication,
+	// paginating until exhausted, for the publications whose sitemap is incomplete.
+	// The API is used anyway when the sitemap lists no posts.
+	UseArchiveAPI bool
+
+	// VerifyChecksums makes the file downloads, e.g. the audio of the posts, record the SHA-256 sum of each file
+	// in a <file>.sha256 sidecar, and download again the existing files which don't match it rather than skipping them.
+	VerifyChecksums bool
+
+	// KeepRaw makes ExtractPost keep the JSON data each post is extracted from, returned by Post.RawJSON.
+	// It is off by default, since that data is much larger than the post itself.
+	KeepRaw bool
 }
 
 // NewExtractor creates a new Extractor with the provided Fetcher.
@@ -170,36 +672,84 @@ func extractJSONString(scriptContent string) (string, error) {
 	return scriptContent[start+len("JSON.parse(\"") : end], nil
 }
 
-func (e *Extractor) ExtractPost(ctx context.Context, pageUrl string) (Post, error) {
+// extractPreloads fetches the page at pageUrl and returns the JSON data of its window._preloads script,
+// along with the final URL of the page after following any redirect.
+func (e *Extractor) extractPreloads(ctx context.Context, pageUrl string) (RawPost, string, error) {
 	// fetch page HTML content
-	body, err := e.fetcher.FetchURL(ctx, pageUrl)
+	res, err := e.fetcher.FetchURLFull(ctx, pageUrl)
 	if err != nil {
-		return Post{}, fmt.Errorf("failed to fetch page: %s", err)
+		return RawPost{}, "", err
 	}
-	defer body.Close()
+	defer res.Body.Close()
+	finalUrl := res.FinalURL
 
-	doc, err := goquery.NewDocumentFromReader(body)
+	page, err := io.ReadAll(res.Body)
 	if err != nil {
-		return Post{}, fmt.Errorf("failed to fetch page: %s", err)
+		return RawPost{}, "", err
+	}
+	rawJSON, err := preloadsFromPage(page)
+	if err != nil {
+		return RawPost{}, "", err
+	}
 
+	return rawJSON, finalUrl, nil
+}
+
+// preloadsFromPage extracts the JSON data embedded in the window._preloads script of the page HTML.
+// If it cannot be decoded, e.g. because of control characters, the page is cleaned up and decoded again,
+// and the changes made are recorded in the RawPost.
+func preloadsFromPage(page []byte) (RawPost, error) {
+	rawJSON, err := decodePreloads(string(page))
+	if err == nil {
+		return rawJSON, nil
+	}
+	cleaned, changes := cleanHTML(string(page))
+	if len(changes) == 0 {
+		return RawPost{}, err
+	}
+	rawJSON, retryErr := decodePreloads(cleaned)
+	if retryErr != nil {
+		return RawPost{}, err
+	}
+	rawJSON.cleanup = strings.Join(changes, ", ")
+	return rawJSON, nil
+}
+
+// decodePreloads decodes the JSON data embedded in the window._preloads script of the page HTML.
+func decodePreloads(page string) (RawPost, error) {
+	doc, err := parseHTML(page)
+	if err != nil {
+		return RawPost{}, err
 	}
 
 	scriptContent := findScriptContent(doc)
 
 	if scriptContent == "" {
-		return Post{}, fmt.Errorf("failed to fetch page: script content not found")
+		return RawPost{}, errors.New("script content not found")
 	}
 
 	jsonString, err := extractJSONString(scriptContent)
 	if err != nil {
-		return Post{}, fmt.Errorf("failed to fetch page: %s", err)
+		return RawPost{}, err
 	}
 
 	// jsonString is a stringified JSON string. Convert it to a normal JSON string
-	var rawJSON RawPost
+	rawJSON := RawPost{page: doc}
 	err = json.Unmarshal([]byte("\""+jsonString+"\""), &rawJSON.str) //json.NewEncoder(&rawJSON).Encode([]byte("\"" + jsonString + "\""))
 	if err != nil {
-		return Post{}, fmt.Errorf("failed to fetch page: %s", err)
+		return RawPost{}, err
+	}
+
+	return rawJSON, nil
+}
+
+// ExtractPost fetches the post at pageUrl and extracts its data.
+// If pageUrl redirects (e.g. a short or aliased link), the final URL is used
+// to fill in the canonical URL and slug when the page data lacks them.
+func (e *Extractor) ExtractPost(ctx context.Context, pageUrl string) (Post, error) {
+	rawJSON, finalUrl, err := e.extractPreloads(ctx, pageUrl)
+	if err != nil {
+		return Post{}, fmt.Errorf("failed to fetch page: %w", err)
 	}
 
 	// Now convert the normal JSON string to a Go object
@@ -207,13 +757,182 @@ func (e *Extractor) ExtractPost(ctx context.Context, pageUrl string) (Post, erro
 	if err != nil {
 		return Post{}, fmt.Errorf("failed to fetch page: %s", err)
 	}
+	if e.KeepRaw {
+		p.raw = rawJSON.str
+	}
+	p.pageCleanup = rawJSON.cleanup
+	p.requestedUrl = pageUrl
+	if len(p.Transcript) == 0 && p.PodcastEpisode != nil {
+		p.Transcript = p.PodcastEpisode.Transcript
+	}
+
+	if finalUrl == "" {
+		finalUrl = pageUrl
+	}
+	if p.CanonicalUrl == "" {
+		p.CanonicalUrl = normalizeURL(finalUrl)
+	}
+	if p.Slug == "" {
+		p.Slug = slugFromURL(finalUrl)
+	}
+	if p.isTruncated() && !p.ShouldShowPaywall && !p.IsPaid() {
+		// the body is loaded lazily behind a "continue reading" interstitial: get it from the API instead.
+		// The body of a paywalled post is short too, but the API doesn't have more of it without a valid cookie
+		if body, err := e.fetchAPIBody(ctx, finalUrl, p.Slug); err == nil && len(body) > len(p.BodyHTML) {
+			p.BodyHTML = body
+		}
+	}
+	if strings.TrimSpace(p.BodyHTML) == "" && e.BodySelector != "" && rawJSON.page != nil {
+		// the content is only rendered in the page
+		if body, err := rawJSON.page.Find(e.BodySelector).First().Html(); err == nil {
+			p.BodyHTML = strings.TrimSpace(body)
+		}
+	}
+	if len(p.Polls) > 0 {
+		// the poll results are not part of the body: render them in place of their placeholders
+		p.BodyHTML, err = renderPolls(p.BodyHTML, p.Polls)
+		if err != nil {
+			return Post{}, fmt.Errorf("failed to render polls: %s", err)
+		}
+	}
+	for _, u := range []string{pageUrl, finalUrl} {
+		if u != p.CanonicalUrl {
+			p.alternateUrls = append(p.alternateUrls, u)
+		}
+	}
 
 	return p, nil
 }
 
+// IsPaid reports whether the Post is only for paid subscribers.
+func (p *Post) IsPaid() bool {
+	return p.Audience == "only_paid" || p.Audience == "founding"
+}
+
+// IsPaywalled reports whether the Post's body is only a preview, cut by the paywall:
+// the page was served with a paywall, or the post is for paid subscribers and its body is much shorter than its word count.
+func (p *Post) IsPaywalled() bool {
+	return p.ShouldShowPaywall || (p.IsPaid() && p.isTruncated())
+}
+
+// truncatedBodyRatio is the share of the post word count below which the body is considered truncated.
+const truncatedBodyRatio = 0.5
+
+// isTruncated reports whether the Post's body is much shorter than its word count,
+// as it happens when the page only embeds the beginning of the post.
+func (p *Post) isTruncated() bool {
+	if p.WordCount == 0 {
+		return false
+	}
+	words := len(strings.Fields(html2text.HTML2Text(p.BodyHTML)))
+	return float64(words) < float64(p.WordCount)*truncatedBodyRatio
+}
+
+// fetchAPIBody fetches the post with the given slug from the API of the publication at pageUrl and returns its HTML body.
+func (e *Extractor) fetchAPIBody(ctx context.Context, pageUrl string, slug string) (string, error) {
+	u, err := url.Parse(pageUrl)
+	if err != nil {
+		return "", err
+	}
+	var p Post
+	apiUrl := fmt.Sprintf("%s://%s/api/v1/posts/%s", u.Scheme, u.Host, url.PathEscape(slug))
+	if err := e.fetchJSON(ctx, apiUrl, &p); err != nil {
+		return "", err
+	}
+	return p.BodyHTML, nil
+}
+
+// slugFromURL returns the last non-empty path segment of a post URL, without query and fragment.
+// e.g. https://example.substack.com/p/this-is-the-post-title/?utm_source=x -> this-is-the-post-title
+func slugFromURL(postUrl string) string {
+	u, err := url.Parse(postUrl)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// normalizeURL returns the post URL without its query and fragment, e.g. the tracking parameters of a shared link:
+// https://example.substack.com/p/slug?utm_source=x#comments -> https://example.substack.com/p/slug
+func normalizeURL(postUrl string) string {
+	u, err := url.Parse(postUrl)
+	if err != nil {
+		return postUrl
+	}
+	u.RawQuery, u.Fragment, u.RawFragment, u.ForceQuery = "", "", "", false
+	return u.String()
+}
+
+// fetchJSON fetches the specified URL and decodes its JSON response body into v.
+func (e *Extractor) fetchJSON(ctx context.Context, url string, v any) error {
+	body, err := e.fetcher.FetchURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return json.NewDecoder(body).Decode(v)
+}
+
 type DateFilterFunc func(string) bool
 
 func (e *Extractor) GetAllPostsURLs(ctx context.Context, pubUrl string, f DateFilterFunc) ([]string, error) {
+	entries, err := e.getListing(ctx, pubUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := []string{}
+	for _, entry := range entries {
+		// if the date filter function is not nil, check if the post date complies with the filter
+		if f != nil && !f(entry.Lastmod) {
+			continue
+		}
+		urls = append(urls, entry.Url)
+	}
+	return urls, nil
+}
+
+// getListing returns the posts listed in the sitemap of the publication at pubUrl, along with the ones listed
+// by its archive API with UseArchiveAPI or when the sitemap has none, from the listing cache when it has a recent enough copy.
+func (e *Extractor) getListing(ctx context.Context, pubUrl string) ([]ListingEntry, error) {
+	// the listings with and without the archive API are cached apart, the former having more posts
+	cacheKey := pubUrl
+	if e.UseArchiveAPI {
+		cacheKey += "#archive-api"
+	}
+	if e.ListingCache != nil {
+		if entries, ok := e.ListingCache.Get(cacheKey); ok {
+			return entries, nil
+		}
+	}
+
+	entries, err := e.getSitemapListing(ctx, pubUrl)
+	if e.UseArchiveAPI || len(entries) == 0 {
+		// some publications truncate their sitemap, or don't list their posts in it:
+		// the API completes it when it lists posts, otherwise the sitemap, or its error, is all there is
+		if archiveEntries, archiveErr := e.getArchiveListing(ctx, pubUrl); archiveErr == nil && len(archiveEntries) > 0 {
+			entries, err = mergeListings(entries, archiveEntries), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		// the listing is incomplete: don't store it
+		return entries, nil
+	}
+
+	if e.ListingCache != nil {
+		// a failure only means the listing will be fetched again next time
+		e.ListingCache.Put(cacheKey, entries)
+	}
+	return entries, nil
+}
+
+// getSitemapListing returns the posts listed in the sitemap of the publication at pubUrl.
+func (e *Extractor) getSitemapListing(ctx context.Context, pubUrl string) ([]ListingEntry, error) {
 	u, err := url.Parse(pubUrl)
 	if err != nil {
 		return nil, err
@@ -237,7 +956,7 @@ func (e *Extractor) GetAllPostsURLs(ctx context.Context, pubUrl string, f DateFi
 		return nil, err
 	}
 
-	urls := []string{}
+	entries := []ListingEntry{}
 	doc.Find("url").EachWithBreak(func(i int, s *goquery.Selection) bool {
 		// Check if the context has been cancelled
 		select {
@@ -252,38 +971,135 @@ func (e *Extractor) GetAllPostsURLs(ctx context.Context, pubUrl string, f DateFi
 		if !strings.Contains(url, "/p/") {
 			return true
 		}
-		// if the date filter function is not nil, check if the post date complies with the filter
-		if f != nil && !f(lastmod) {
-			return true
-		}
-		urls = append(urls, url)
+		entries = append(entries, ListingEntry{Url: url, Lastmod: lastmod})
 
 		return true
 	})
-
-	return urls, nil
+	return entries, nil
 }
 
 type ExtractResult struct {
+	// Url is the url the post was extracted from, set even when the extraction failed.
+	Url  string
 	Post Post
 	Err  error
 }
 
+// DefaultWorkers is the default number of posts extracted at the same time by ExtractAllPosts.
+// The actual request rate is still bound by the Fetcher's rate limiter.
+const DefaultWorkers = 10
+
+// ExtractAllPosts extracts the posts at the given urls and sends them to the returned channel as they are ready.
+// Only a few posts are extracted ahead of the consumer, so memory usage stays bounded however large the archive is.
+// The channel is closed once all the posts are extracted, or as soon as the context is cancelled.
 func (e *Extractor) ExtractAllPosts(ctx context.Context, urls []string) <-chan ExtractResult {
-	ch := make(chan ExtractResult, len(urls))
+	return e.extractAllPosts(ctx, urls, false)
+}
+
+// ExtractAllPostsOrdered works like ExtractAllPosts, but it sends the posts in the same order as urls,
+// so that the output built from them, e.g. a combined file or an index, is the same across runs.
+// The posts extracted ahead of their turn are buffered: a post which is slow to extract holds back the following ones,
+// and at most twice as many posts as Workers are extracted or buffered at the same time, which bounds the memory used.
+func (e *Extractor) ExtractAllPostsOrdered(ctx context.Context, urls []string) <-chan ExtractResult {
+	return e.extractAllPosts(ctx, urls, true)
+}
+
+// extractAllPosts extracts the posts at the given urls with a pool of workers,
+// sending them to the returned channel as they are ready or, if ordered is true, in the same order as urls.
+func (e *Extractor) extractAllPosts(ctx context.Context, urls []string, ordered bool) <-chan ExtractResult {
+	workers := e.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	type job struct {
+		index int
+		url   string
+	}
+	type result struct {
+		index int
+		ExtractResult
+	}
+	ch := make(chan ExtractResult, workers)
+	jobs := make(chan job)
+	results := make(chan result)
+	// in order, slots bounds the number of posts extracted or buffered ahead of the next one to send
+	var slots chan struct{}
+	if ordered {
+		slots = make(chan struct{}, 2*workers)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(urls); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				post, err := e.ExtractPost(ctx, j.url)
+				select {
+				case results <- result{index: j.index, ExtractResult: ExtractResult{Url: j.url, Post: post, Err: err}}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
 
 	go func() {
-		var wg sync.WaitGroup
-		wg.Add(len(urls))
-		for _, u := range urls {
-			go func(url string) {
-				defer wg.Done()
-				post, err := e.ExtractPost(ctx, url)
-				ch <- ExtractResult{Post: post, Err: err}
-			}(u)
+		defer close(jobs)
+		for i, u := range urls {
+			if ordered {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case jobs <- job{index: i, url: u}:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
+
+	go func() {
 		wg.Wait()
-		close(ch)
+		close(results)
+	}()
+
+	go func() {
+		defer close(ch)
+		send := func(r ExtractResult) bool {
+			select {
+			case ch <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		pending := make(map[int]ExtractResult)
+		next := 0
+		for r := range results {
+			if !ordered {
+				if !send(r.ExtractResult) {
+					return
+				}
+				continue
+			}
+			pending[r.index] = r.ExtractResult
+			for {
+				res, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				if !send(res) {
+					return
+				}
+				<-slots
+				next++
+			}
+		}
 	}()
 
 	return ch
@@ -0,0 +1,172 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteToFilePreserveModTime(t *testing.T) {
+	postDate := time.Date(2023, 5, 17, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		date     string
+		format   string
+		opts     []WriteOption
+		ext      string // the extension added to the path by the options
+		wantPost bool
+	}{
+		{"html", postDate.Format(time.RFC3339), "html", []WriteOption{WithPreserveModTime()}, "", true},
+		{"md", postDate.Format(time.RFC3339), "md", []WriteOption{WithPreserveModTime()}, "", true},
+		{"gzip", postDate.Format(time.RFC3339), "html", []WriteOption{WithPreserveModTime(), WithGzip()}, ".gz", true},
+		{"unparseable date", "17 May 2023", "html", []WriteOption{WithPreserveModTime()}, "", false},
+		{"without the option", postDate.Format(time.RFC3339), "html", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Post{Title: "Post", PostDate: tt.date, BodyHTML: "<p>Body</p>"}
+			path := filepath.Join(t.TempDir(), "post."+tt.format)
+			start := time.Now().Add(-time.Minute)
+			if err := p.WriteToFile(path, tt.format, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path + tt.ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantPost && !info.ModTime().Equal(postDate) {
+				t.Errorf("mtime = %s, want the post date %s", info.ModTime(), postDate)
+			}
+			if !tt.wantPost && info.ModTime().Before(start) {
+				t.Errorf("mtime = %s, want the current time", info.ModTime())
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		want     string
+		wantSlug string
+	}{
+		{"https://example.substack.com/p/test-post", "https://example.substack.com/p/test-post", "test-post"},
+		{"https://example.substack.com/p/test-post?utm_source=newsletter", "https://example.substack.com/p/test-post", "test-post"},
+		{"https://example.substack.com/p/test-post#comments", "https://example.substack.com/p/test-post", "test-post"},
+		{"https://example.substack.com/p/test-post/?r=abc&utm_medium=email#footnote-1", "https://example.substack.com/p/test-post/", "test-post"},
+		{"https://example.com/p/test-post?", "https://example.com/p/test-post", "test-post"},
+	}
+	for _, tt := range tests {
+		if got := normalizeURL(tt.url); got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+		if got := slugFromURL(tt.url); got != tt.wantSlug {
+			t.Errorf("slugFromURL(%q) = %q, want %q", tt.url, got, tt.wantSlug)
+		}
+	}
+}
+
+func TestUseRequestedURL(t *testing.T) {
+	const canonicalUrl = "https://example.substack.com/p/test-post"
+	tests := []struct {
+		name        string
+		requested   string
+		wantChanged bool
+		wantUrl     string
+		wantSlug    string
+	}{
+		{"same url", canonicalUrl, false, canonicalUrl, "test-post"},
+		{"same url with tracking parameters", canonicalUrl + "?utm_source=newsletter#comments", false, canonicalUrl, "test-post"},
+		{"custom domain", "https://example.com/p/test-post?utm_source=share", true, "https://example.com/p/test-post", "test-post"},
+		{"alias", "https://example.substack.com/p/old-slug#comments", true, "https://example.substack.com/p/old-slug", "old-slug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Post{CanonicalUrl: canonicalUrl, Slug: "test-post", requestedUrl: tt.requested}
+			if changed := p.UseRequestedURL(); changed != tt.wantChanged {
+				t.Errorf("UseRequestedURL() = %v, want %v", changed, tt.wantChanged)
+			}
+			if p.CanonicalUrl != tt.wantUrl || p.Slug != tt.wantSlug {
+				t.Errorf("got url %q and slug %q, want %q and %q", p.CanonicalUrl, p.Slug, tt.wantUrl, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestToJSONOptionalFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		post    Post
+		key     string
+		wantKey bool
+	}{
+		{"without email body", Post{Title: "Post"}, `"email_body"`, false},
+		{"with email body", Post{Title: "Post", EmailBodyHTML: "<p>Email</p>"}, `"email_body"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.post.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(got, tt.key) != tt.wantKey {
+				t.Errorf("ToJSON() = %s, want the key %s: %v", got, tt.key, tt.wantKey)
+			}
+		})
+	}
+}
+
+// preloadsPage returns a post page embedding the data in its window._preloads script, as Substack does.
+func preloadsPage(t testing.TB, data any) string {
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted, err := json.Marshal(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("<html><head><title>Post</title></head><body><script>window._preloads = JSON.parse(%s)</script></body></html>", quoted)
+}
+
+func TestExtractPostRedirect(t *testing.T) {
+	// the page data has no canonical url nor slug: they are derived from the url after the redirect
+	data := map[string]any{"post": map[string]any{"id": 1, "title": "The post", "post_date": "2024-03-15T10:00:00Z", "body_html": "<p>Body</p>"}}
+	mux := http.NewServeMux()
+	mux.Handle("/short", http.RedirectHandler("/p/real-slug", http.StatusMovedPermanently))
+	mux.HandleFunc("/p/real-slug", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(preloadsPage(t, data)))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := NewExtractor(NewFetcher(WithRatePerSecond(100))).ExtractPost(context.Background(), srv.URL+"/short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/p/real-slug"; p.CanonicalUrl != want {
+		t.Errorf("CanonicalUrl = %q, want %q", p.CanonicalUrl, want)
+	}
+	if p.Slug != "real-slug" {
+		t.Errorf("Slug = %q, want %q", p.Slug, "real-slug")
+	}
+
+	path := filepath.Join(t.TempDir(), p.Slug+".html")
+	if err := p.WriteToFile(path, "html"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<p>Body</p>") {
+		t.Errorf("the written file has %q", b)
+	}
+}
@@ -1,12 +1,18 @@
 package lib
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -29,8 +35,23 @@ const defaultMaxElapsedTime = 10 * time.Minute
 // defaultMaxInterval defines the default maximum interval for the exponential backoff.
 const defaultMaxInterval = 2 * time.Minute
 
-// userAgent specifies the User-Agent header value used in HTTP requests.
-const userAgent = "sbstck-dl/0.1"
+// DefaultRetryableStatusCodes lists the status codes, besides 429 (too many requests), for which a request is retried by default.
+// These are transient server errors, often returned by CDNs; any other unexpected status code fails right away.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// ErrMaxRequests is returned, without sending the request, once the Fetcher has sent its maximum number of requests.
+var ErrMaxRequests = errors.New("maximum number of requests reached")
+
+// Version is the version of sbstck-dl.
+const Version = "0.3.2"
+
+// DefaultUserAgent is the User-Agent header value used in HTTP requests, unless set with WithUserAgent.
+const DefaultUserAgent = "sbstck-dl/" + Version
 
 // Fetcher represents a URL fetcher with rate limiting and retry mechanisms.
 type Fetcher struct {
@@ -38,6 +59,39 @@ type Fetcher struct {
 	RateLimiter *rate.Limiter
 	BackoffCfg  backoff.BackOff
 	Cookie      *http.Cookie
+	// HostCookies holds the cookies to send instead of Cookie to some hosts, by host name,
+	// e.g. to download several private publications, each with its own session, in the same run.
+	HostCookies map[string]*http.Cookie
+	// CookieHosts holds the host names, besides the Substack domains, which Cookie and APIToken are sent to,
+	// e.g. the custom domain of the publication. The other hosts, like CDNs, get no credentials.
+	CookieHosts map[string]bool
+	// UserAgent is the User-Agent header value sent with the requests.
+	UserAgent string
+	// APIToken, if not empty, is sent as a bearer token in the Authorization header of the requests to the Substack API.
+	APIToken string
+	// AdaptiveRate, if not nil, adjusts the rate of RateLimiter based on the outcome of the requests.
+	AdaptiveRate *AdaptiveLimiter
+	// RetryableStatusCodes holds the status codes, besides 429, for which a request is retried with backoff.
+	RetryableStatusCodes map[int]bool
+	// MaxRequests, if positive, is the maximum number of requests sent by the Fetcher, retries included.
+	MaxRequests int64
+	// MinDelay, if positive, is the minimum time between two consecutive requests to the same host,
+	// enforced on top of RateLimiter, which allows bursts.
+	MinDelay time.Duration
+	// Cache, if not nil, stores the pages and the API responses, which are then only downloaded again once changed.
+	Cache *HTTPCache
+
+	// nextRequest holds, by host, the earliest time the next request can be sent to it with MinDelay.
+	nextRequest   map[string]time.Time
+	nextRequestMu sync.Mutex
+
+	// requestCount is the number of requests sent so far.
+	requestCount atomic.Int64
+
+	// pausedUntil is the time until which no request is sent, set when the server answers with too many requests.
+	// It is shared by all the requests of the Fetcher, so that they all back off together.
+	pausedUntil time.Time
+	pauseMu     sync.Mutex
 }
 
 // FetcherOptions holds configurable options for Fetcher.
@@ -46,6 +100,18 @@ type FetcherOptions struct {
 	ProxyURL      *url.URL
 	BackOffConfig backoff.BackOff
 	Cookie        *http.Cookie
+	AdaptiveRate  bool
+	// RetryableStatusCodes, if not nil, replaces DefaultRetryableStatusCodes.
+	RetryableStatusCodes []int
+	MaxRequests          int64
+	// InsecureSkipVerify disables the verification of the TLS certificates.
+	InsecureSkipVerify bool
+	APIToken           string
+	HostCookies        map[string]*http.Cookie
+	CookieHosts        []string
+	MinDelay           time.Duration
+	UserAgent          string
+	CacheDir           string
 }
 
 // FetcherOption defines a function that applies a specific option to FetcherOptions.
@@ -81,6 +147,93 @@ func WithCookie(cookie *http.Cookie) FetcherOption {
 	}
 }
 
+// WithHostCookies sets the cookies to send to some hosts, by host name, instead of the cookie set with WithCookie.
+// The host names are matched case-insensitively against the host of each request, port excluded.
+func WithHostCookies(cookies map[string]*http.Cookie) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.HostCookies = make(map[string]*http.Cookie, len(cookies))
+		for host, cookie := range cookies {
+			o.HostCookies[strings.ToLower(host)] = cookie
+		}
+	}
+}
+
+// WithCookieHosts adds hosts, besides the Substack domains, which the cookie set with WithCookie and the API token are sent to,
+// e.g. the custom domain of the publication. The host names are matched case-insensitively, port excluded.
+func WithCookieHosts(hosts ...string) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.CookieHosts = append(o.CookieHosts, hosts...)
+	}
+}
+
+// WithAdaptiveRate makes the Fetcher lower its rate when the server answers with too many requests,
+// and raise it back, up to the configured rate, after a series of successful requests.
+func WithAdaptiveRate() FetcherOption {
+	return func(o *FetcherOptions) {
+		o.AdaptiveRate = true
+	}
+}
+
+// WithRetryableStatusCodes sets the status codes, besides 429 (too many requests), for which the Fetcher retries a request.
+// Any other unexpected status code fails without retrying. Passing no codes disables the retry on server errors.
+func WithRetryableStatusCodes(codes ...int) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.RetryableStatusCodes = append([]int{}, codes...)
+	}
+}
+
+// WithMaxRequests caps the number of requests sent by the Fetcher, retries included:
+// once the cap is reached, every fetch fails with ErrMaxRequests. A value of 0 means no limit.
+func WithMaxRequests(n int64) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.MaxRequests = n
+	}
+}
+
+// WithUserAgent sets the User-Agent header value sent with the requests, instead of DefaultUserAgent,
+// e.g. for the publications which block or throttle unknown clients.
+func WithUserAgent(userAgent string) FetcherOption {
+	return func(o *FetcherOptions) {
+		if userAgent != "" {
+			o.UserAgent = userAgent
+		}
+	}
+}
+
+// WithMinDelay sets the minimum time between two consecutive requests to the same host,
+// for the servers which block clients sending requests too close to each other, whatever their average rate.
+// The requests to a host are serialized so that they are at least d apart.
+func WithMinDelay(d time.Duration) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.MinDelay = d
+	}
+}
+
+// WithAPIToken sets the token sent, as a bearer token, with the requests to the Substack API (the /api/ paths),
+// as an alternative to the session cookie for authenticated API requests.
+func WithAPIToken(token string) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.APIToken = token
+	}
+}
+
+// WithCache sets the directory where the Fetcher stores the pages and the API responses,
+// which are then requested with their ETag and Last-Modified validators, and reused when unchanged.
+func WithCache(dir string) FetcherOption {
+	return func(o *FetcherOptions) {
+		o.CacheDir = dir
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of the TLS certificates of the servers,
+// e.g. to archive a custom domain with an expired or mismatched certificate.
+// It makes the connections vulnerable to man-in-the-middle attacks: use it only when needed.
+func WithInsecureSkipVerify() FetcherOption {
+	return func(o *FetcherOptions) {
+		o.InsecureSkipVerify = true
+	}
+}
+
 // FetchResult represents the result of a URL fetch operation.
 type FetchResult struct {
 	Url   string
@@ -88,15 +241,31 @@ type FetchResult struct {
 	Error error
 }
 
-// FetchError represents an error returned when encountering too many requests with a Retry-After value.
+// FetchResponse represents a successful response of a URL fetch operation, along with its metadata.
+type FetchResponse struct {
+	Body       io.ReadCloser
+	Header     http.Header
+	StatusCode int
+	// FinalURL is the URL the response was served from, which differs from the requested one after a redirect.
+	FinalURL string
+	// ContentLength is the size of the body as reported by the server, or -1 if unknown.
+	ContentLength int64
+}
+
+// FetchError represents an error returned when the server answers with an unexpected status code.
+// When encountering too many requests, TooManyRequests is set along with the Retry-After value.
 type FetchError struct {
+	StatusCode      int
 	TooManyRequests bool
 	RetryAfter      int
 }
 
-// Error returns the error message for the FetchError, indicating the retry wait time.
+// Error returns the error message for the FetchError, indicating the retry wait time in case of too many requests.
 func (e *FetchError) Error() string {
-	return fmt.Sprintf("too many requests, retry after %d seconds", e.RetryAfter)
+	if e.TooManyRequests {
+		return fmt.Sprintf("too many requests, retry after %d seconds", e.RetryAfter)
+	}
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
 }
 
 // NewFetcher creates a new Fetcher with the provided options.
@@ -106,6 +275,7 @@ func NewFetcher(opts ...FetcherOption) *Fetcher {
 	options := FetcherOptions{
 		RatePerSecond: DefaultRatePerSecond,
 		BackOffConfig: makeDefaultBackoff(),
+		UserAgent:     DefaultUserAgent,
 	}
 
 	for _, opt := range opts {
@@ -116,15 +286,51 @@ func NewFetcher(opts ...FetcherOption) *Fetcher {
 	if options.ProxyURL != nil {
 		transport = &http.Transport{Proxy: http.ProxyURL(options.ProxyURL)}
 	}
+	if options.InsecureSkipVerify {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if options.ProxyURL != nil {
+			t.Proxy = http.ProxyURL(options.ProxyURL)
+		}
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		transport = t
+	}
 
 	client := &http.Client{Transport: transport}
 
-	return &Fetcher{
-		Client:      client,
-		RateLimiter: rate.NewLimiter(rate.Limit(options.RatePerSecond), 1),
-		BackoffCfg:  options.BackOffConfig,
-		Cookie:      options.Cookie,
+	retryableCodes := DefaultRetryableStatusCodes
+	if options.RetryableStatusCodes != nil {
+		retryableCodes = options.RetryableStatusCodes
+	}
+	retryable := make(map[int]bool, len(retryableCodes))
+	for _, code := range retryableCodes {
+		retryable[code] = true
+	}
+
+	f := &Fetcher{
+		Client:               client,
+		RateLimiter:          rate.NewLimiter(rate.Limit(options.RatePerSecond), 1),
+		BackoffCfg:           options.BackOffConfig,
+		Cookie:               options.Cookie,
+		HostCookies:          options.HostCookies,
+		APIToken:             options.APIToken,
+		RetryableStatusCodes: retryable,
+		MaxRequests:          options.MaxRequests,
+		MinDelay:             options.MinDelay,
+		UserAgent:            options.UserAgent,
+		nextRequest:          make(map[string]time.Time),
+	}
+	f.CookieHosts = make(map[string]bool, len(options.CookieHosts))
+	for _, host := range options.CookieHosts {
+		f.CookieHosts[strings.ToLower(host)] = true
 	}
+	if options.AdaptiveRate {
+		f.AdaptiveRate = NewAdaptiveLimiter(f.RateLimiter)
+	}
+	if options.CacheDir != "" {
+		f.Cache = &HTTPCache{Dir: options.CacheDir}
+	}
+
+	return f
 }
 
 // FetchURLs concurrently fetches the specified URLs and returns a channel to receive the FetchResults.
@@ -164,8 +370,39 @@ func (f *Fetcher) FetchURLs(ctx context.Context, urls []string) <-chan FetchResu
 // FetchURL fetches the specified URL and returns the response body as io.ReadCloser and any encountered error.
 // It uses rate limiting and retry mechanisms to handle rate limits and transient failures.
 func (f *Fetcher) FetchURL(ctx context.Context, url string) (io.ReadCloser, error) {
+	body, _, err := f.FetchURLWithFinalURL(ctx, url)
+	return body, err
+}
 
-	var body io.ReadCloser
+// FetchURLWithFinalURL works like FetchURL, but it also returns the URL the response was served from,
+// which differs from the requested one when the server redirected the request.
+func (f *Fetcher) FetchURLWithFinalURL(ctx context.Context, url string) (io.ReadCloser, string, error) {
+	res, err := f.FetchURLFull(ctx, url)
+	if err != nil {
+		return nil, "", err
+	}
+	return res.Body, res.FinalURL, nil
+}
+
+// RequestOption defines a function that customizes a single request of the Fetcher, e.g. adding a header.
+type RequestOption func(*http.Request)
+
+// WithReferer sets the Referer header of the request, which some CDNs require to match the page the resource is used in.
+func WithReferer(referer string) RequestOption {
+	return func(req *http.Request) {
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+	}
+}
+
+// FetchURLFull works like FetchURL, but it returns the whole response: the body along with the headers,
+// the status code, the final URL after any redirect, and the content length.
+// The options customize the request, including its retries.
+// The caller is responsible for closing the body of the response.
+func (f *Fetcher) FetchURLFull(ctx context.Context, url string, opts ...RequestOption) (*FetchResponse, error) {
+
+	var res *FetchResponse
 	var err error
 	var retryCounter int
 	var nextRetryWait time.Duration
@@ -178,13 +415,20 @@ func (f *Fetcher) FetchURL(ctx context.Context, url string) (io.ReadCloser, erro
 		if nextRetryWait > 0 {
 			time.Sleep(nextRetryWait)
 		}
+		err = f.waitPause(ctx) // Wait for any cool-down after too many requests
+		if err != nil {
+			return err // Context cancellation
+		}
 		err = f.RateLimiter.Wait(ctx) // Use rate limiter
 		if err != nil {
 			return err // Could be a context cancellation or error in limiter
 		}
-		body, err = f.fetch(ctx, url)
+		res, err = f.fetch(ctx, url, opts...)
 		if err != nil {
 			retryCounter++
+			if !f.isRetryable(err) {
+				return backoff.Permanent(err) // e.g. 404, 401 or 403: retrying won't help
+			}
 		}
 		return err
 	}
@@ -198,31 +442,104 @@ func (f *Fetcher) FetchURL(ctx context.Context, url string) (io.ReadCloser, erro
 		}
 	}
 
-	backoff.RetryNotify(operation, f.BackoffCfg, notify)
+	// stop retrying as soon as the context is cancelled
+	backoff.RetryNotify(operation, backoff.WithContext(f.BackoffCfg, ctx), notify)
 
-	return body, err
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FetchContentLength sends a HEAD request to the specified URL and returns the size of its content,
+// as reported by the Content-Length header, or -1 if unknown.
+// It uses the rate limiting of the Fetcher but, unlike FetchURL, it doesn't retry on failures.
+func (f *Fetcher) FetchContentLength(ctx context.Context, url string) (int64, error) {
+	if err := f.waitPause(ctx); err != nil {
+		return 0, err
+	}
+	if err := f.RateLimiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", f.userAgent())
+	f.addCookie(req)
+	f.addAPIToken(req)
+
+	if err := f.waitMinDelay(ctx, req.URL.Hostname()); err != nil {
+		return 0, err
+	}
+	if err := f.countRequest(); err != nil {
+		return 0, err
+	}
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	return res.ContentLength, nil
 }
 
-// fetch performs the actual HTTP GET request to the specified URL and returns the response body and any encountered error.
+// fetch performs the actual HTTP GET request to the specified URL and returns the response,
+// including the final URL after following any redirect, and any encountered error.
 // It checks for too many requests (status code 429) and handles it by returning a FetchError.
-func (f *Fetcher) fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+func (f *Fetcher) fetch(ctx context.Context, url string, opts ...RequestOption) (*FetchResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("User-Agent", f.userAgent())
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	f.addCookie(req)
+	f.addAPIToken(req)
 
-	// Add cookie to the request if it's not nil
-	if f.Cookie != nil {
-		req.AddCookie(f.Cookie)
+	var cached cachedResponse
+	var hasCached bool
+	if f.Cache != nil {
+		cached, hasCached = f.Cache.addValidators(req)
 	}
 
+	if err := f.waitMinDelay(ctx, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	if err := f.countRequest(); err != nil {
+		return nil, err
+	}
 	res, err := f.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
+	if res.StatusCode == http.StatusNotModified && hasCached {
+		res.Body.Close()
+		if f.AdaptiveRate != nil {
+			f.AdaptiveRate.OnSuccess()
+		}
+		header := res.Header.Clone()
+		header.Set("Content-Type", cached.ContentType)
+		return &FetchResponse{
+			Body:          io.NopCloser(strings.NewReader(cached.Body)),
+			Header:        header,
+			StatusCode:    http.StatusOK,
+			FinalURL:      cached.FinalURL,
+			ContentLength: int64(len(cached.Body)),
+		}, nil
+	}
+
 	if res.StatusCode == http.StatusTooManyRequests {
+		res.Body.Close()
 		retryAfter := defaultRetryAfter
 		if retryAfterStr := res.Header.Get("Retry-After"); retryAfterStr != "" {
 			retryAfter, err = strconv.Atoi(retryAfterStr)
@@ -230,14 +547,193 @@ func (f *Fetcher) fetch(ctx context.Context, url string) (io.ReadCloser, error)
 				return nil, fmt.Errorf("invalid Retry-After header: %v", err)
 			}
 		}
-		return nil, &FetchError{TooManyRequests: true, RetryAfter: retryAfter}
+		f.pause(time.Duration(retryAfter) * time.Second)
+		if f.AdaptiveRate != nil {
+			f.AdaptiveRate.OnTooManyRequests()
+		}
+		return nil, &FetchError{StatusCode: res.StatusCode, TooManyRequests: true, RetryAfter: retryAfter}
 	}
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+		res.Body.Close()
+		return nil, &FetchError{StatusCode: res.StatusCode}
+	}
+
+	if f.AdaptiveRate != nil {
+		f.AdaptiveRate.OnSuccess()
+	}
+
+	if f.Cache != nil && isCacheable(res.Header) {
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// a failure to store the response only means it is downloaded again next time
+		f.Cache.put(cachedResponse{
+			Url:          req.URL.String(),
+			FinalURL:     res.Request.URL.String(),
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			ContentType:  res.Header.Get("Content-Type"),
+			Body:         string(body),
+		})
+		res.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return &FetchResponse{
+		Body:          res.Body,
+		Header:        res.Header,
+		StatusCode:    res.StatusCode,
+		FinalURL:      res.Request.URL.String(),
+		ContentLength: res.ContentLength,
+	}, nil
+}
+
+// CookieFor returns the cookie sent to host: its own one, if any, or else the Fetcher's cookie if host is trusted
+// with the credentials, which can be nil. The other hosts, e.g. the CDNs serving the images and the audio, get no cookie.
+func (f *Fetcher) CookieFor(host string) *http.Cookie {
+	if cookie, ok := f.HostCookies[strings.ToLower(host)]; ok {
+		return cookie
+	}
+	if !f.isCredentialHost(host) {
+		return nil
 	}
+	return f.Cookie
+}
 
-	return res.Body, nil
+// isCredentialHost reports whether the credentials of the Fetcher, its cookies and API token, are sent to host:
+// whether it is a Substack domain, one of the CookieHosts, or has its own cookie.
+func (f *Fetcher) isCredentialHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "substack.com" || strings.HasSuffix(host, ".substack.com") {
+		return true
+	}
+	_, hasCookie := f.HostCookies[host]
+	return f.CookieHosts[host] || hasCookie
+}
+
+// userAgent returns the User-Agent header value of the requests: the Fetcher's one, or DefaultUserAgent if not set.
+func (f *Fetcher) userAgent() string {
+	if f.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return f.UserAgent
+}
+
+// addCookie adds the cookie for the host of the request, if any, to the request.
+func (f *Fetcher) addCookie(req *http.Request) {
+	if cookie := f.CookieFor(req.URL.Hostname()); cookie != nil {
+		req.AddCookie(cookie)
+	}
+}
+
+// addAPIToken adds the Fetcher's API token, if any, to the request if it is sent to the Substack API of a trusted host.
+func (f *Fetcher) addAPIToken(req *http.Request) {
+	if f.APIToken != "" && strings.HasPrefix(req.URL.Path, "/api/") && f.isCredentialHost(req.URL.Hostname()) {
+		req.Header.Set("Authorization", "Bearer "+f.APIToken)
+	}
+}
+
+// isRetryable reports whether a request that failed with err should be retried.
+// Too many requests and network errors, such as timeouts and dropped connections, are always retried,
+// while an unexpected status code is only retried if it is one of the Fetcher's RetryableStatusCodes.
+// A malformed URL is never retried.
+func (f *Fetcher) isRetryable(err error) bool {
+	if errors.Is(err, ErrMaxRequests) {
+		return false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Op == "parse" {
+		return false
+	}
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.TooManyRequests {
+		return true
+	}
+	return f.RetryableStatusCodes[fetchErr.StatusCode]
+}
+
+// RequestCount returns the number of requests sent so far by the Fetcher.
+func (f *Fetcher) RequestCount() int64 {
+	return f.requestCount.Load()
+}
+
+// countRequest counts a request about to be sent, or returns ErrMaxRequests if the cap is reached.
+func (f *Fetcher) countRequest() error {
+	if f.MaxRequests <= 0 {
+		f.requestCount.Add(1)
+		return nil
+	}
+	for {
+		n := f.requestCount.Load()
+		if n >= f.MaxRequests {
+			return ErrMaxRequests
+		}
+		if f.requestCount.CompareAndSwap(n, n+1) {
+			return nil
+		}
+	}
+}
+
+// pause stops all the requests of the Fetcher for the duration d.
+// If a longer pause is already in place, it is left untouched.
+func (f *Fetcher) pause(d time.Duration) {
+	f.pauseMu.Lock()
+	defer f.pauseMu.Unlock()
+	if until := time.Now().Add(d); until.After(f.pausedUntil) {
+		f.pausedUntil = until
+	}
+}
+
+// waitPause blocks until the current pause, if any, is over or the context is cancelled.
+func (f *Fetcher) waitPause(ctx context.Context) error {
+	f.pauseMu.Lock()
+	d := time.Until(f.pausedUntil)
+	f.pauseMu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitMinDelay waits until a request can be sent to host with the Fetcher's MinDelay,
+// reserving its slot so that the concurrent requests to the same host are spaced out too.
+func (f *Fetcher) waitMinDelay(ctx context.Context, host string) error {
+	if f.MinDelay <= 0 {
+		return nil
+	}
+	f.nextRequestMu.Lock()
+	if f.nextRequest == nil {
+		f.nextRequest = make(map[string]time.Time)
+	}
+	at := time.Now()
+	if next := f.nextRequest[host]; next.After(at) {
+		at = next
+	}
+	f.nextRequest[host] = at.Add(f.MinDelay)
+	f.nextRequestMu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // makeDefaultBackoff creates and returns the default exponential backoff configuration.
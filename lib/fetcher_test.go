@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+func TestCookieFor(t *testing.T) {
+	cookie := &http.Cookie{Name: "substack.sid", Value: "secret"}
+	hostCookie := &http.Cookie{Name: "substack.sid", Value: "other"}
+	f := NewFetcher(
+		WithCookie(cookie),
+		WithCookieHosts("www.example.com"),
+		WithHostCookies(map[string]*http.Cookie{"private.example.org": hostCookie}),
+	)
+
+	tests := []struct {
+		host string
+		want *http.Cookie
+	}{
+		{"example.substack.com", cookie},
+		{"substack.com", cookie},
+		{"www.example.com", cookie},
+		{"WWW.Example.com", cookie},
+		{"private.example.org", hostCookie},
+		{"substackcdn.com", nil},
+		{"cdn.example.net", nil},
+		{"evilsubstack.com", nil},
+	}
+	for _, tt := range tests {
+		if got := f.CookieFor(tt.host); got != tt.want {
+			t.Errorf("CookieFor(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+// credentialServer returns a server recording whether its last request had a cookie or an Authorization header.
+func credentialServer(t *testing.T, gotCredentials *bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*gotCredentials = r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != ""
+		w.Write([]byte("data"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchSendsCredentialsToTrustedHostsOnly(t *testing.T) {
+	var gotCredentials bool
+	srv := credentialServer(t, &gotCredentials)
+	// the server is reached as 127.0.0.1: only localhost is trusted
+	f := NewFetcher(WithCookie(&http.Cookie{Name: "substack.sid", Value: "secret"}), WithAPIToken("token"), WithCookieHosts("localhost"))
+	ctx := context.Background()
+
+	if _, err := f.FetchContentLength(ctx, srv.URL+"/api/v1/file/x.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if gotCredentials {
+		t.Error("FetchContentLength sent the credentials to a third-party host")
+	}
+
+	res, err := f.FetchURLFull(ctx, srv.URL+"/api/v1/posts/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if gotCredentials {
+		t.Error("FetchURLFull sent the credentials to a third-party host")
+	}
+
+	trusted := NewFetcher(WithCookie(&http.Cookie{Name: "substack.sid", Value: "secret"}), WithCookieHosts("127.0.0.1"))
+	if _, err := trusted.FetchContentLength(ctx, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if !gotCredentials {
+		t.Error("FetchContentLength didn't send the cookie to the publication")
+	}
+}
+
+func TestFetchRetriesStatusCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		opts         []FetcherOption
+		wantErr      bool
+		wantRequests int
+	}{
+		{"503 twice then 200", []int{503, 503, 200}, nil, false, 3},
+		{"502 then 200", []int{502, 200}, nil, false, 2},
+		{"404 fails fast", []int{404, 200}, nil, true, 1},
+		{"403 fails fast", []int{403, 200}, nil, true, 1},
+		{"custom codes", []int{418, 200}, []FetcherOption{WithRetryableStatusCodes(418)}, false, 2},
+		{"retry disabled", []int{503, 200}, []FetcherOption{WithRetryableStatusCodes()}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tt.codes[len(tt.codes)-1]
+				if requests < len(tt.codes) {
+					code = tt.codes[requests]
+				}
+				requests++
+				w.WriteHeader(code)
+			}))
+			defer srv.Close()
+
+			opts := append([]FetcherOption{
+				WithRatePerSecond(100),
+				WithBackOffConfig(backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 5)),
+			}, tt.opts...)
+			body, err := NewFetcher(opts...).FetchURL(context.Background(), srv.URL)
+			if err == nil {
+				body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchURL error = %v, want error: %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestReferer(t *testing.T) {
+	const postUrl = "https://example.substack.com/p/post"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != postUrl {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+	e := NewExtractor(NewFetcher(WithRatePerSecond(100)))
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		fetch   func(referer string) error
+		referer string
+		wantErr bool
+	}{
+		{"image with referer", func(referer string) error {
+			_, _, err := e.FetchImage(ctx, srv.URL+"/image.png", referer)
+			return err
+		}, postUrl, false},
+		{"image without referer", func(referer string) error {
+			_, _, err := e.FetchImage(ctx, srv.URL+"/image.png", referer)
+			return err
+		}, "", true},
+		{"cover with referer", func(referer string) error {
+			_, err := e.DownloadCover(ctx, &Post{CanonicalUrl: referer, CoverImage: srv.URL + "/cover.png"}, filepath.Join(t.TempDir(), "post.cover"))
+			return err
+		}, postUrl, false},
+		{"cover with another referer", func(referer string) error {
+			_, err := e.DownloadCover(ctx, &Post{CanonicalUrl: referer, CoverImage: srv.URL + "/cover.png"}, filepath.Join(t.TempDir(), "post.cover"))
+			return err
+		}, "https://example.com/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fetch(tt.referer)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// frontMatterField is a single key/value pair of the YAML front matter of a post.
+// The value can be a string, an int, or a []string.
+type frontMatterField struct {
+	key   string
+	value any
+}
+
+// FrontMatterPreset selects the fields of the front matter, to match the conventions of a static site generator.
+type FrontMatterPreset string
+
+const (
+	// FrontMatterGeneric is the default preset, with the fields understood by most tools.
+	FrontMatterGeneric FrontMatterPreset = ""
+	// FrontMatterHugo adds the fields used by Hugo, such as draft.
+	FrontMatterHugo FrontMatterPreset = "hugo"
+	// FrontMatterJekyll adds the fields used by Jekyll, such as layout.
+	FrontMatterJekyll FrontMatterPreset = "jekyll"
+)
+
+// frontMatterFields returns the fields of the Post's front matter for the preset, in the order they are written.
+// Empty values are left out.
+func (p *Post) frontMatterFields(preset FrontMatterPreset) []frontMatterField {
+	switch preset {
+	case FrontMatterHugo:
+		return []frontMatterField{
+			{"title", p.Title},
+			{"date", p.PostDate},
+			{"lastmod", p.UpdatedAt},
+			{"draft", false},
+			{"slug", p.Slug},
+			{"description", p.Description},
+			{"canonical_url", p.CanonicalUrl},
+			{"tags", p.Tags()},
+			{"aliases", p.Aliases()},
+		}
+	case FrontMatterJekyll:
+		// Jekyll takes the slug from the file name, and has no built-in support for aliases
+		return []frontMatterField{
+			{"layout", "post"},
+			{"title", p.Title},
+			{"date", p.PostDate},
+			{"last_modified_at", p.UpdatedAt},
+			{"description", p.Description},
+			{"canonical_url", p.CanonicalUrl},
+			{"tags", p.Tags()},
+		}
+	}
+	return []frontMatterField{
+		{"title", p.Title},
+		{"date", p.PostDate},
+		{"updated_at", p.UpdatedAt},
+		{"slug", p.Slug},
+		{"description", p.Description},
+		{"canonical_url", p.CanonicalUrl},
+		{"tags", p.Tags()},
+		{"aliases", p.Aliases()},
+	}
+}
+
+// FrontMatter returns the Post's metadata as a YAML front matter block,
+// as used by static site generators and note-taking apps like Hugo, Jekyll or Obsidian.
+func (p *Post) FrontMatter() string {
+	return p.FrontMatterFor(FrontMatterGeneric)
+}
+
+// FrontMatterFor returns the Post's metadata as a YAML front matter block with the fields of the preset.
+func (p *Post) FrontMatterFor(preset FrontMatterPreset) string {
+	return renderFrontMatter(p.frontMatterFields(preset))
+}
+
+// renderFrontMatter renders the fields as a YAML front matter block, skipping the empty ones.
+func renderFrontMatter(fields []frontMatterField) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, f := range fields {
+		switch v := f.value.(type) {
+		case string:
+			if v != "" {
+				fmt.Fprintf(&sb, "%s: %s\n", f.key, strconv.Quote(v))
+			}
+		case int:
+			fmt.Fprintf(&sb, "%s: %d\n", f.key, v)
+		case bool:
+			fmt.Fprintf(&sb, "%s: %t\n", f.key, v)
+		case []string:
+			if len(v) > 0 {
+				fmt.Fprintf(&sb, "%s:\n", f.key)
+				for _, item := range v {
+					fmt.Fprintf(&sb, "  - %s\n", strconv.Quote(item))
+				}
+			}
+		}
+	}
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
+
+// Aliases returns the URL paths the Post is known by: the path of its canonical URL,
+// followed by the ones of any other URL it was requested or redirected from.
+func (p *Post) Aliases() []string {
+	var aliases []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{p.CanonicalUrl}, p.alternateUrls...) {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Path == "" {
+			continue
+		}
+		alias := "/" + strings.Trim(parsed.Path, "/")
+		if !seen[alias] {
+			seen[alias] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HTTPCache stores the responses of the Fetcher in files, along with their ETag and Last-Modified validators,
+// so that the next requests to the same URL are conditional and an unchanged page is not downloaded again.
+// Only the pages and the API responses are stored, not the media files.
+// The files are written atomically, so the cache can be shared by concurrent requests.
+type HTTPCache struct {
+	// Dir is the directory the responses are stored in, one file per URL.
+	Dir string
+}
+
+// cachedResponse is the content of a response file.
+type cachedResponse struct {
+	Url          string `json:"url"`
+	FinalURL     string `json:"final_url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Body         string `json:"body"`
+}
+
+// path returns the path of the response file of the URL.
+func (c *HTTPCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, "response-"+hex.EncodeToString(sum[:16])+".json")
+}
+
+// get returns the stored response of the URL, if any.
+func (c *HTTPCache) get(url string) (cachedResponse, bool) {
+	b, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return cachedResponse{}, false
+	}
+	var res cachedResponse
+	if err := json.Unmarshal(b, &res); err != nil || res.Url != url {
+		return cachedResponse{}, false
+	}
+	return res, true
+}
+
+// put stores the response of the URL.
+func (c *HTTPCache) put(res cachedResponse) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return writeFile(c.path(res.Url), string(b))
+}
+
+// addValidators makes the request conditional on the stored response of its URL, if any,
+// and returns the stored response.
+func (c *HTTPCache) addValidators(req *http.Request) (cachedResponse, bool) {
+	res, ok := c.get(req.URL.String())
+	if !ok {
+		return res, false
+	}
+	if res.ETag != "" {
+		req.Header.Set("If-None-Match", res.ETag)
+	}
+	if res.LastModified != "" {
+		req.Header.Set("If-Modified-Since", res.LastModified)
+	}
+	return res, true
+}
+
+// isCacheable reports whether the response with the header is stored: it must have a validator,
+// and be a page or an API response rather than a media file.
+func isCacheable(header http.Header) bool {
+	if header.Get("ETag") == "" && header.Get("Last-Modified") == "" {
+		return false
+	}
+	if strings.Contains(header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+xml") || mediaType == "application/xml"
+}
@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mdLinkRegex matches the targets of the links and images of a Markdown document.
+var mdLinkRegex = regexp.MustCompile(`\]\(([^)\s]+)`)
+
+// DanglingReferences returns the local paths referenced by the html or md file at path which don't exist on disk,
+// e.g. an image that failed to download while its reference was rewritten. Relative paths are resolved
+// against the directory of the file. Files compressed with gzip (.gz) are checked too. Remote URLs, fragments and data URIs are not checked.
+// Files in any other format have no references to check.
+func DanglingReferences(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	if ext == ".gz" {
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		content, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		ext = filepath.Ext(strings.TrimSuffix(path, ext))
+	}
+
+	var refs []string
+	switch ext {
+	case ".html":
+		doc, err := parseHTML(string(content))
+		if err != nil {
+			return nil, err
+		}
+		doc.Find("[src], [href], [srcset]").Each(func(i int, s *goquery.Selection) {
+			for _, attr := range []string{"src", "href"} {
+				if v, ok := s.Attr(attr); ok {
+					refs = append(refs, v)
+				}
+			}
+			if srcset, ok := s.Attr("srcset"); ok {
+				for _, candidate := range strings.Split(srcset, ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						refs = append(refs, fields[0])
+					}
+				}
+			}
+		})
+	case ".md":
+		for _, m := range mdLinkRegex.FindAllStringSubmatch(string(content), -1) {
+			refs = append(refs, m[1])
+		}
+	}
+
+	var dangling []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		local, ok := localPath(ref)
+		if !ok || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if !filepath.IsAbs(local) {
+			local = filepath.Join(filepath.Dir(path), local)
+		}
+		if _, err := os.Stat(local); err != nil {
+			dangling = append(dangling, ref)
+		}
+	}
+	return dangling, nil
+}
+
+// localPath returns the file path referenced by ref, if ref is a local reference rather than a remote URL.
+func localPath(ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") {
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ListingEntry is a post of the archive listing, along with its last modification date as found in the sitemap.
+type ListingEntry struct {
+	Url     string `json:"url"`
+	Lastmod string `json:"lastmod"`
+}
+
+// ListingCache stores the archive listings of the publications in files,
+// so that the commands run within its TTL reuse them instead of fetching the sitemap again.
+// The listings are stored unfiltered, so that any date filter can be applied to them.
+type ListingCache struct {
+	// Dir is the directory the listings are stored in, one file per publication and listing method.
+	Dir string
+	// TTL is how long a listing is reused after being fetched.
+	TTL time.Duration
+	// Refresh makes Get ignore the stored listings, which are then replaced by the next Put.
+	Refresh bool
+}
+
+// cachedListing is the content of a listing file.
+type cachedListing struct {
+	PubUrl    string         `json:"publication_url"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Entries   []ListingEntry `json:"entries"`
+}
+
+// path returns the path of the listing file of the publication at pubUrl.
+func (c *ListingCache) path(pubUrl string) string {
+	sum := sha256.Sum256([]byte(pubUrl))
+	return filepath.Join(c.Dir, "listing-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// Get returns the stored listing of the publication at pubUrl, if it was fetched within the TTL.
+func (c *ListingCache) Get(pubUrl string) ([]ListingEntry, bool) {
+	if c.Refresh || c.TTL <= 0 {
+		return nil, false
+	}
+	b, err := os.ReadFile(c.path(pubUrl))
+	if err != nil {
+		return nil, false
+	}
+	var listing cachedListing
+	if err := json.Unmarshal(b, &listing); err != nil {
+		return nil, false
+	}
+	if listing.PubUrl != pubUrl || time.Since(listing.FetchedAt) > c.TTL {
+		return nil, false
+	}
+	return listing.Entries, true
+}
+
+// Put stores the listing of the publication at pubUrl.
+func (c *ListingCache) Put(pubUrl string, entries []ListingEntry) error {
+	if c.TTL <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(cachedListing{PubUrl: pubUrl, FetchedAt: time.Now(), Entries: entries})
+	if err != nil {
+		return err
+	}
+	return writeFile(c.path(pubUrl), string(b))
+}
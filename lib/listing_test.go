@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// listingServer returns a publication serving the sitemap and the archive API bodies, or a 404 for an empty body.
+func listingServer(t *testing.T, sitemap *string, archive *string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := *archive
+		if r.URL.Path == "/sitemap.xml" {
+			body = *sitemap
+		}
+		if body == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetListingFallback(t *testing.T) {
+	var sitemap, archive string
+	srv := listingServer(t, &sitemap, &archive)
+	sitemapPost := fmt.Sprintf(`<urlset><url><loc>%s/p/a</loc><lastmod>2024-01-01</lastmod></url></urlset>`, srv.URL)
+	archivePost := fmt.Sprintf(`[{"title":"B","slug":"b","canonical_url":"%s/p/b","post_date":"2024-02-01T10:00:00Z"}]`, srv.URL)
+
+	tests := []struct {
+		name        string
+		sitemap     string
+		archive     string
+		wantErr     bool
+		wantEntries int
+	}{
+		{"sitemap fails, API empty", "", "[]", true, 0},
+		{"sitemap fails, API fails", "", "", true, 0},
+		{"sitemap fails, API lists posts", "", archivePost, false, 1},
+		{"sitemap lists posts", sitemapPost, "[]", false, 1},
+		{"empty sitemap, API lists posts", "<urlset></urlset>", archivePost, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sitemap, archive = tt.sitemap, tt.archive
+			e := NewExtractor(NewFetcher(WithRatePerSecond(100)))
+			entries, err := e.getListing(context.Background(), srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getListing error = %v, want error: %v", err, tt.wantErr)
+			}
+			if len(entries) != tt.wantEntries {
+				t.Errorf("got %d entries, want %d", len(entries), tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestGetListingCacheByMethod(t *testing.T) {
+	var sitemap, archive string
+	srv := listingServer(t, &sitemap, &archive)
+	sitemap = fmt.Sprintf(`<urlset><url><loc>%s/p/a</loc><lastmod>2024-01-01</lastmod></url></urlset>`, srv.URL)
+	archive = fmt.Sprintf(`[{"title":"B","slug":"b","canonical_url":"%s/p/b","post_date":"2024-02-01T10:00:00Z"}]`, srv.URL)
+
+	e := NewExtractor(NewFetcher(WithRatePerSecond(100)))
+	e.ListingCache = &ListingCache{Dir: t.TempDir(), TTL: time.Hour}
+	ctx := context.Background()
+
+	tests := []struct {
+		useAPI      bool
+		wantEntries int
+	}{
+		{false, 1},
+		// the listing cached without the API misses the posts only listed by it
+		{true, 2},
+		{false, 1},
+	}
+	for _, tt := range tests {
+		e.UseArchiveAPI = tt.useAPI
+		entries, err := e.getListing(ctx, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != tt.wantEntries {
+			t.Errorf("with UseArchiveAPI = %v, got %d entries, want %d", tt.useAPI, len(entries), tt.wantEntries)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// minimalRemoveSelector matches the nodes that are dropped, along with their content, when making a post body minimal:
+// embeds, and the Substack widgets (subscribe prompts, share bars, buttons, footnotes, etc.).
+const minimalRemoveSelector = "script, style, noscript, iframe, svg, video, audio, button, form, input, nav, header, footer, aside, " +
+	".subscription-widget-wrap, .subscription-widget, .subscribe-widget, .share-dialog, .post-ufi, .button-wrapper, " +
+	".embedded-post-wrap, .digest-post-embed, " +
+	".tweet, .youtube-wrap, .spotify-wrap, .poll-embed, .install-substack-app-embed, .paywall, " +
+	".footnote-anchor, .footnote"
+
+// minimalImagesSelector matches the images and their containers, also dropped by MinimalHTML.
+const minimalImagesSelector = "img, picture, figure, .captioned-image-container, .image-link, .image-gallery-embed"
+
+// minimalKeepTags lists the elements kept when making a post body minimal.
+// Any other element is replaced by its content.
+var minimalKeepTags = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"p": true, "ul": true, "ol": true, "li": true, "blockquote": true,
+	"pre": true, "code": true, "a": true, "em": true, "strong": true, "i": true, "b": true,
+	"br": true, "hr": true,
+}
+
+// minimalImageTags lists the elements also kept by EReaderHTML, for the images and their captions.
+var minimalImageTags = map[string]bool{
+	"img": true, "figure": true, "figcaption": true,
+}
+
+// MinimalHTML strips the post body down to its prose: headings, paragraphs, lists, blockquotes, and code blocks.
+// Widgets, media and any other non-content node are removed, and all the attributes but link targets are dropped.
+func MinimalHTML(bodyHTML string) (string, error) {
+	return minimalHTML(bodyHTML, false)
+}
+
+// EReaderHTML works like MinimalHTML, but it keeps the images, with their captions, for e-readers.
+// The images only keep their source and alternative text: without their size, they are scaled to the screen by the stylesheet.
+func EReaderHTML(bodyHTML string) (string, error) {
+	return minimalHTML(bodyHTML, true)
+}
+
+func minimalHTML(bodyHTML string, keepImages bool) (string, error) {
+	doc, err := parseHTML(bodyHTML)
+	if err != nil {
+		return "", err
+	}
+	body := doc.Find("body")
+
+	body.Find(minimalRemoveSelector).Remove()
+	if !keepImages {
+		body.Find(minimalImagesSelector).Remove()
+	}
+
+	body.Find("*").Each(func(i int, s *goquery.Selection) {
+		tag := goquery.NodeName(s)
+		if !minimalKeepTags[tag] && !(keepImages && minimalImageTags[tag]) {
+			s.ReplaceWithSelection(s.Contents())
+			return
+		}
+		node := s.Get(0)
+		href, hasHref := s.Attr("href")
+		src, hasSrc := s.Attr("src")
+		alt, hasAlt := s.Attr("alt")
+		node.Attr = nil
+		if tag == "a" && hasHref {
+			s.SetAttr("href", href)
+		}
+		if tag == "img" {
+			if !hasSrc {
+				s.Remove()
+				return
+			}
+			s.SetAttr("src", src)
+			if hasAlt {
+				s.SetAttr("alt", alt)
+			}
+		}
+	})
+
+	// drop the paragraphs left empty by the removals
+	body.Find("p").Each(func(i int, s *goquery.Selection) {
+		if strings.TrimSpace(s.Text()) == "" && s.Find("br, img").Length() == 0 {
+			s.Remove()
+		}
+	})
+
+	return body.Html()
+}
+
+// StripStyling removes the class, style and data-* attributes from the post body, which carry the Substack layout
+// rather than the content. The attributes the content needs, such as the sources of the images and the link targets, are kept.
+func StripStyling(bodyHTML string) (string, error) {
+	doc, err := parseHTML(bodyHTML)
+	if err != nil {
+		return "", err
+	}
+	body := doc.Find("body")
+
+	body.Find("*").Each(func(i int, s *goquery.Selection) {
+		node := s.Get(0)
+		attrs := node.Attr[:0]
+		for _, a := range node.Attr {
+			if a.Key == "class" || a.Key == "style" || strings.HasPrefix(a.Key, "data-") {
+				continue
+			}
+			attrs = append(attrs, a)
+		}
+		node.Attr = attrs
+	})
+
+	return body.Html()
+}
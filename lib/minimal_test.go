@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEReaderHTML(t *testing.T) {
+	const image = `<div class="captioned-image-container"><figure><a class="image-link" href="https://substackcdn.com/image/full.png">` +
+		`<picture><source type="image/webp" srcset="https://substackcdn.com/image/small.webp 424w"/>` +
+		`<img src="https://substackcdn.com/image/small.png" srcset="https://substackcdn.com/image/small.png 424w" width="1456" height="816" alt="A chart" class="sizing-normal"/></picture>` +
+		`<div class="image-link-expand"><button><svg></svg></button></div></a>` +
+		`<figcaption class="image-caption">The caption</figcaption></figure></div>`
+	tests := []struct {
+		name    string
+		body    string
+		minimal string
+		ereader string
+	}{
+		{"prose", `<p class="x">Text <strong>bold</strong></p>`, `<p>Text <strong>bold</strong></p>`, `<p>Text <strong>bold</strong></p>`},
+		{"captioned image", `<p>Before</p>` + image,
+			`<p>Before</p>`,
+			`<p>Before</p><figure><a href="https://substackcdn.com/image/full.png"><img src="https://substackcdn.com/image/small.png" alt="A chart"/></a><figcaption>The caption</figcaption></figure>`},
+		{"inline image", `<p><img src="https://example.com/a.png"/></p>`, ``, `<p><img src="https://example.com/a.png"/></p>`},
+		{"image without source", `<p>Text<img alt="x"/></p>`, `<p>Text</p>`, `<p>Text</p>`},
+		{"widget", `<p>Text</p><div class="subscription-widget-wrap"><p>Subscribe</p></div>`, `<p>Text</p>`, `<p>Text</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minimal, err := MinimalHTML(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if minimal != tt.minimal {
+				t.Errorf("MinimalHTML() = %s, want %s", minimal, tt.minimal)
+			}
+			ereader, err := EReaderHTML(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ereader != tt.ereader {
+				t.Errorf("EReaderHTML() = %s, want %s", ereader, tt.ereader)
+			}
+		})
+	}
+}
+
+func TestMinimalHTMLFixture(t *testing.T) {
+	body, err := os.ReadFile("testdata/minimal-body.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := MinimalHTML(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h2>A heading</h2>",
+		`<p>First paragraph, with <a href="https://example.com/">a link</a> and <em>emphasis</em>.</p>`,
+		"<ul><li>One</li><li>Two</li></ul>",
+		"<blockquote><p>A quote</p></blockquote>",
+		"<pre><code>code block</code></pre>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("MinimalHTML() lost %s: %s", want, got)
+		}
+	}
+	for _, removed := range []string{"<nav", "Home", "<button", "<img", "<figure", "The caption", "Subscribe", "<form", "<iframe", "footnote", "class=", "style=", "target="} {
+		if strings.Contains(got, removed) {
+			t.Errorf("MinimalHTML() kept %s: %s", removed, got)
+		}
+	}
+}
+
+func TestStripStyling(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"prose", `<p class="x" style="color: red" data-foo="bar">Text <a class="link" href="https://example.com/">link</a></p>`,
+			`<p>Text <a href="https://example.com/">link</a></p>`},
+		{"captioned image",
+			`<div class="captioned-image-container"><figure><a class="image-link" href="https://substackcdn.com/image/full.png" data-component-name="Image2ToDOM">` +
+				`<picture><source type="image/webp" srcset="https://substackcdn.com/image/small.webp 424w"/>` +
+				`<img src="https://substackcdn.com/image/small.png" srcset="https://substackcdn.com/image/small.png 424w" width="1456" height="816" alt="A chart" class="sizing-normal" data-attrs="{}"/></picture></a>` +
+				`<figcaption class="image-caption">The caption</figcaption></figure></div>`,
+			`<div><figure><a href="https://substackcdn.com/image/full.png">` +
+				`<picture><source type="image/webp" srcset="https://substackcdn.com/image/small.webp 424w"/>` +
+				`<img src="https://substackcdn.com/image/small.png" srcset="https://substackcdn.com/image/small.png 424w" width="1456" height="816" alt="A chart"/></picture></a>` +
+				`<figcaption>The caption</figcaption></figure></div>`},
+		{"inline image", `<p><img style="width: 100%" src="data:image/png;base64,iVBORw0KGgo=" alt=""/></p>`,
+			`<p><img src="data:image/png;base64,iVBORw0KGgo=" alt=""/></p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripStyling(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("StripStyling() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// substackBaseUrl is the main Substack website, which hosts the reader and profile APIs.
+const substackBaseUrl = "https://substack.com"
+
+// Note represents a Substack note published by a user.
+type Note struct {
+	Id            int         `json:"id"`
+	Body          string      `json:"body"`
+	Date          string      `json:"date"`
+	ReactionCount int         `json:"reaction_count"`
+	Restacks      int         `json:"restacks"`
+	User          CommentUser `json:"user"`
+}
+
+// rawNote mirrors the note objects returned by the Substack API,
+// where the author fields are inlined in the note itself.
+type rawNote struct {
+	CommentUser
+	Id            int    `json:"id"`
+	Body          string `json:"body"`
+	Date          string `json:"date"`
+	ReactionCount int    `json:"reaction_count"`
+	Restacks      int    `json:"restacks"`
+}
+
+// toNote converts the rawNote to a Note.
+func (r rawNote) toNote() Note {
+	return Note{
+		Id:            r.Id,
+		Body:          r.Body,
+		Date:          r.Date,
+		ReactionCount: r.ReactionCount,
+		Restacks:      r.Restacks,
+		User:          r.CommentUser,
+	}
+}
+
+// noteURLRegex matches the URL of a single note, on substack.com (/@handle/note/c-123, /profile/1-handle/note/c-123)
+// or on the domain of a publication (/note/c-123), capturing the note id.
+var noteURLRegex = regexp.MustCompile(`/note/c-(\d+)/?$`)
+
+// noteResponse is the payload returned by the single note endpoint.
+type noteResponse struct {
+	Item struct {
+		Comment *rawNote `json:"comment"`
+	} `json:"item"`
+	Comment *rawNote `json:"comment"`
+}
+
+// IsNoteURL reports whether noteUrl is the URL of a single note.
+func IsNoteURL(noteUrl string) bool {
+	_, ok := noteID(noteUrl)
+	return ok
+}
+
+// noteID returns the id of the note at noteUrl.
+func noteID(noteUrl string) (int, bool) {
+	u, err := url.Parse(noteUrl)
+	if err != nil {
+		return 0, false
+	}
+	m := noteURLRegex.FindStringSubmatch(u.Path)
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(m[1])
+	return id, err == nil
+}
+
+// GetNote returns the single note at noteUrl, e.g. https://substack.com/@handle/note/c-123.
+func (e *Extractor) GetNote(ctx context.Context, noteUrl string) (Note, error) {
+	id, ok := noteID(noteUrl)
+	if !ok {
+		return Note{}, fmt.Errorf("not a note url: %s", noteUrl)
+	}
+
+	var res noteResponse
+	if err := e.fetchJSON(ctx, fmt.Sprintf("%s/api/v1/reader/comment/%d", substackBaseUrl, id), &res); err != nil {
+		return Note{}, fmt.Errorf("failed to fetch note: %w", err)
+	}
+	raw := res.Item.Comment
+	if raw == nil {
+		raw = res.Comment
+	}
+	if raw == nil {
+		return Note{}, fmt.Errorf("note not found: %s", noteUrl)
+	}
+	return raw.toNote(), nil
+}
+
+// Render returns the note in the specified format (json, html, md, txt, or org).
+func (n *Note) Render(format string) (string, error) {
+	var sb strings.Builder
+	switch format {
+	case "json":
+		b, err := json.Marshal(n)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case "html":
+		fmt.Fprintf(&sb, "<p><strong>%s</strong> <time>%s</time></p>\n", html.EscapeString(n.User.Name), html.EscapeString(n.Date))
+	case "md":
+		fmt.Fprintf(&sb, "**%s** (%s)\n\n", n.User.Name, n.Date)
+	case "txt":
+		fmt.Fprintf(&sb, "%s (%s)\n\n", n.User.Name, n.Date)
+	case "org":
+		fmt.Fprintf(&sb, "*%s* (%s)\n\n", n.User.Name, n.Date)
+	default:
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+	for _, paragraph := range strings.Split(n.Body, "\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+			continue
+		}
+		if format == "html" {
+			fmt.Fprintf(&sb, "<p>%s</p>\n", html.EscapeString(paragraph))
+		} else {
+			sb.WriteString(paragraph + "\n\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// WriteToFile writes the note to a file in the specified format (json, html, md, txt, or org).
+func (n *Note) WriteToFile(path string, format string) error {
+	content, err := n.Render(format)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, content)
+}
+
+// publicProfile is the subset of a user's public profile needed to fetch their notes.
+type publicProfile struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Handle string `json:"handle"`
+}
+
+// notesFeedResponse is a single page of a user's profile feed.
+type notesFeedResponse struct {
+	Items []struct {
+		Type    string   `json:"type"`
+		Comment *rawNote `json:"comment"`
+	} `json:"items"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// GetNotes returns all the notes published by the user with the given handle,
+// from the most recent to the oldest.
+// The handle can be provided with or without the leading "@".
+func (e *Extractor) GetNotes(ctx context.Context, handle string) ([]Note, error) {
+	return e.GetNotesSince(ctx, handle, time.Time{})
+}
+
+// GetNotesSince works like GetNotes, but it only returns the notes published after since,
+// e.g. the date of the newest note of a previous run, to keep a notes archive up to date.
+// Since the notes come from the most recent, it stops fetching pages as soon as it reaches an older note.
+// A zero since returns all the notes.
+func (e *Extractor) GetNotesSince(ctx context.Context, handle string, since time.Time) ([]Note, error) {
+	handle = strings.TrimPrefix(handle, "@")
+
+	var profile publicProfile
+	profileUrl := fmt.Sprintf("%s/api/v1/user/%s/public_profile", substackBaseUrl, url.PathEscape(handle))
+	if err := e.fetchJSON(ctx, profileUrl, &profile); err != nil {
+		return nil, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+
+	notes := []Note{}
+	cursor := ""
+	for {
+		feedUrl := fmt.Sprintf("%s/api/v1/reader/feed/profile/%d?types=note", substackBaseUrl, profile.Id)
+		if cursor != "" {
+			feedUrl += "&cursor=" + url.QueryEscape(cursor)
+		}
+
+		var page notesFeedResponse
+		if err := e.fetchJSON(ctx, feedUrl, &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch notes: %w", err)
+		}
+
+		reachedSince := false
+		for _, item := range page.Items {
+			if item.Type != "comment" || item.Comment == nil {
+				continue
+			}
+			note := item.Comment.toNote()
+			if !since.IsZero() {
+				if date, err := time.Parse(time.RFC3339, note.Date); err == nil && !date.After(since) {
+					reachedSince = true
+					break
+				}
+			}
+			notes = append(notes, note)
+		}
+
+		if reachedSince || page.NextCursor == "" || len(page.Items) == 0 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	return notes, nil
+}
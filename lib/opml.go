@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+)
+
+// subscriptionsResponse is the payload returned by the reader subscriptions endpoint.
+type subscriptionsResponse struct {
+	Publications []Publication `json:"publications"`
+}
+
+// GetSubscriptions returns the publications the logged in reader is subscribed to.
+// It requires the Fetcher to be configured with the reader session cookie.
+func (e *Extractor) GetSubscriptions(ctx context.Context) ([]Publication, error) {
+	var res subscriptionsResponse
+	if err := e.fetchJSON(ctx, substackBaseUrl+"/api/v1/subscriptions", &res); err != nil {
+		return nil, fmt.Errorf("failed to fetch subscriptions: %w", err)
+	}
+	return res.Publications, nil
+}
+
+// URL returns the main URL of the Publication, preferring its custom domain if it has one.
+func (p *Publication) URL() string {
+	if p.CustomDomain != "" {
+		return "https://" + p.CustomDomain
+	}
+	return fmt.Sprintf("https://%s.substack.com", p.Subdomain)
+}
+
+// FeedURL returns the URL of the RSS feed of the Publication.
+func (p *Publication) FeedURL() string {
+	return p.URL() + "/feed"
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XMLUrl  string `xml:"xmlUrl,attr"`
+	HTMLUrl string `xml:"htmlUrl,attr"`
+}
+
+type opmlDocument struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Version  string        `xml:"version,attr"`
+	Title    string        `xml:"head>title"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+// ToOPML returns an OPML document listing the RSS feeds of the publications,
+// which can be imported in most RSS readers.
+func ToOPML(title string, pubs []Publication) (string, error) {
+	doc := opmlDocument{Version: "2.0", Title: title}
+	for _, p := range pubs {
+		doc.Outlines = append(doc.Outlines, opmlOutline{
+			Type:    "rss",
+			Text:    p.Name,
+			Title:   p.Name,
+			XMLUrl:  p.FeedURL(),
+			HTMLUrl: p.URL(),
+		})
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b) + "\n", nil
+}
+
+// WriteOPML writes an OPML document listing the RSS feeds of the publications to a file.
+func WriteOPML(path string, title string, pubs []Publication) error {
+	content, err := ToOPML(title, pubs)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, content)
+}
@@ -0,0 +1,219 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ToOrg converts the Post's HTML body to Emacs Org-mode format.
+// If withTitle is true, the title and date of the post are added as Org-mode keywords.
+func (p *Post) ToOrg(withTitle bool) (string, error) {
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if withTitle {
+		fmt.Fprintf(&sb, "#+TITLE: %s\n", p.Title)
+		if p.PostDate != "" {
+			fmt.Fprintf(&sb, "#+DATE: %s\n", p.PostDate)
+		}
+		sb.WriteString("\n")
+	}
+
+	w := orgWriter{sb: &sb}
+	for _, n := range doc.Find("body").Nodes {
+		w.writeBlocks(n, 0)
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n", nil
+}
+
+// orgWriter walks an HTML tree and writes its Org-mode representation.
+type orgWriter struct {
+	sb *strings.Builder
+}
+
+// writeBlocks writes the block-level children of n, indenting list items by depth.
+func (w orgWriter) writeBlocks(n *html.Node, depth int) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.writeBlock(c, depth)
+	}
+}
+
+func (w orgWriter) writeBlock(n *html.Node, depth int) {
+	if n.Type == html.TextNode {
+		if text := strings.TrimSpace(n.Data); text != "" {
+			w.sb.WriteString(collapseSpaces(text) + "\n\n")
+		}
+		return
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		fmt.Fprintf(w.sb, "%s %s\n\n", strings.Repeat("*", level), inlineContent(n))
+	case "p":
+		if text := inlineContent(n); text != "" {
+			w.sb.WriteString(text + "\n\n")
+		}
+	case "ul", "ol":
+		w.writeList(n, depth)
+		if depth == 0 {
+			w.sb.WriteString("\n")
+		}
+	case "pre":
+		fmt.Fprintf(w.sb, "#+BEGIN_SRC\n%s\n#+END_SRC\n\n", strings.TrimRight(textContent(n), "\n"))
+	case "blockquote":
+		w.sb.WriteString("#+BEGIN_QUOTE\n")
+		w.writeBlocks(n, 0)
+		w.sb.WriteString("#+END_QUOTE\n\n")
+	case "hr":
+		w.sb.WriteString("-----\n\n")
+	case "img":
+		w.sb.WriteString(inlineNode(n) + "\n\n")
+	case "figcaption":
+		if text := inlineContent(n); text != "" {
+			fmt.Fprintf(w.sb, "#+CAPTION: %s\n\n", text)
+		}
+	case "script", "style", "button", "svg":
+	default:
+		// containers (div, figure, etc.): write their content
+		w.writeBlocks(n, depth)
+	}
+}
+
+// writeList writes the items of the ul or ol list n, and their nested lists, indented by depth.
+func (w orgWriter) writeList(n *html.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	i := 1
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.Data != "li" {
+			continue
+		}
+		bullet := "-"
+		if n.Data == "ol" {
+			bullet = fmt.Sprintf("%d.", i)
+		}
+		i++
+
+		// the inline content of the item goes on the bullet line, nested lists below it
+		var nested []*html.Node
+		var text strings.Builder
+		for c := item.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.Data == "ul" || c.Data == "ol") {
+				nested = append(nested, c)
+				continue
+			}
+			if t := strings.TrimSpace(collapseSpaces(inlineNode(c))); t != "" {
+				if text.Len() > 0 {
+					text.WriteString(" ")
+				}
+				text.WriteString(t)
+			}
+		}
+		fmt.Fprintf(w.sb, "%s%s %s\n", indent, bullet, text.String())
+		for _, l := range nested {
+			w.writeList(l, depth+1)
+		}
+	}
+}
+
+// inlineContent returns the Org-mode representation of the inline content of n: emphasis, links, code, and images.
+func inlineContent(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(inlineNode(c))
+	}
+	return strings.TrimSpace(collapseSpaces(sb.String()))
+}
+
+// inlineNode returns the Org-mode representation of the inline node n and its content.
+func inlineNode(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	var content strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		content.WriteString(inlineNode(c))
+	}
+	text := content.String()
+
+	wrap := func(marker string) string {
+		if strings.TrimSpace(text) == "" {
+			return text
+		}
+		return marker + strings.TrimSpace(text) + marker
+	}
+
+	switch n.Data {
+	case "em", "i":
+		return wrap("/")
+	case "strong", "b":
+		return wrap("*")
+	case "code":
+		return wrap("~")
+	case "s", "del":
+		return wrap("+")
+	case "br":
+		return "\\\\\n"
+	case "a":
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		if strings.TrimSpace(text) == "" {
+			return fmt.Sprintf("[[%s]]", href)
+		}
+		return fmt.Sprintf("[[%s][%s]]", href, strings.TrimSpace(text))
+	case "img":
+		if src := attr(n, "src"); src != "" {
+			return fmt.Sprintf("[[%s]]", src)
+		}
+		return ""
+	case "script", "style", "button", "svg":
+		return ""
+	default:
+		return text
+	}
+}
+
+// attr returns the value of the attribute key of n, or an empty string.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// textContent returns the text of n and all its descendants, as-is.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+// collapseSpaces replaces the runs of whitespace in s, other than line breaks, with a single space.
+func collapseSpaces(s string) string {
+	lines := strings.Split(s, "\\\\\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\\\\\n")
+}
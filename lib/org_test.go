@@ -0,0 +1,58 @@
+package lib
+
+import "testing"
+
+func TestToOrg(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"headings", `<h1>Title</h1><h2>Section</h2><h3>Sub <em>section</em></h3>`,
+			"* Title\n\n** Section\n\n*** Sub /section/\n"},
+		{"inline markup", `<p>Some <strong>bold</strong>, <em>italic</em>, <code>code</code> and <s>struck</s> text.</p>`,
+			"Some *bold*, /italic/, ~code~ and +struck+ text.\n"},
+		{"links", `<p>A <a href="https://example.com/">link</a>, a <a href="https://example.com/bare"></a> bare one and <a>no target</a>.</p>`,
+			"A [[https://example.com/][link]], a [[https://example.com/bare]] bare one and no target.\n"},
+		{"unordered list", `<ul><li>One</li><li>Two <a href="https://example.com/">link</a></li></ul>`,
+			"- One\n- Two [[https://example.com/][link]]\n"},
+		{"ordered list", `<ol><li>First</li><li>Second</li></ol>`,
+			"1. First\n2. Second\n"},
+		{"nested list", `<ul><li>Parent<ol><li>Child</li><li>Other<ul><li>Deep</li></ul></li></ol></li><li>Next</li></ul>`,
+			"- Parent\n  1. Child\n  2. Other\n    - Deep\n- Next\n"},
+		{"code block", "<pre><code>func main() {\n\tfmt.Println(\"*not bold*\")\n}\n</code></pre>",
+			"#+BEGIN_SRC\nfunc main() {\n\tfmt.Println(\"*not bold*\")\n}\n#+END_SRC\n"},
+		{"quote", `<blockquote><p>Quoted</p></blockquote>`,
+			"#+BEGIN_QUOTE\nQuoted\n\n#+END_QUOTE\n"},
+		{"image with caption", `<figure><img src="https://example.com/a.png"><figcaption>A caption</figcaption></figure>`,
+			"[[https://example.com/a.png]]\n\n#+CAPTION: A caption\n"},
+		{"line break and rule", `<p>One<br>Two</p><hr>`,
+			"One\\\\\nTwo\n\n-----\n"},
+		{"widgets", `<p>Text</p><button>Subscribe</button><script>x()</script>`,
+			"Text\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Post{BodyHTML: tt.body}
+			got, err := p.ToOrg(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ToOrg() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToOrgWithTitle(t *testing.T) {
+	p := Post{Title: "A post", PostDate: "2024-01-10T08:00:00.000Z", BodyHTML: "<p>Text</p>"}
+	got, err := p.ToOrg(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#+TITLE: A post\n#+DATE: 2024-01-10T08:00:00.000Z\n\nText\n"
+	if got != want {
+		t.Errorf("ToOrg() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxHTMLSize is the size, in bytes, a malformed page is truncated to when it is cleaned up,
+// to bound the memory used by pathological pages.
+const maxHTMLSize = 32 << 20
+
+// parseHTML parses the HTML document in s.
+// The parser is tolerant: malformed markup, invalid UTF-8 and NUL bytes are parsed the way a browser does,
+// and an error is only returned if reading the document fails.
+func parseHTML(s string) (*goquery.Document, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return doc, nil
+}
+
+// cleanHTML fixes the HTML document in s so that the data embedded in it can be decoded:
+// invalid UTF-8 sequences are replaced, the control characters but tabs and line breaks are removed,
+// and the document is truncated to maxHTMLSize. It returns the changes made, if any.
+func cleanHTML(s string) (string, []string) {
+	var changes []string
+	if len(s) > maxHTMLSize {
+		changes = append(changes, fmt.Sprintf("truncated from %d to %d bytes", len(s), maxHTMLSize))
+		s = truncateHTML(s, maxHTMLSize)
+	}
+	if !utf8.ValidString(s) {
+		changes = append(changes, "invalid UTF-8 replaced")
+		s = strings.ToValidUTF8(s, "�")
+	}
+	isControl := func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f
+	}
+	if strings.IndexFunc(s, isControl) >= 0 {
+		changes = append(changes, "control characters removed")
+		s = strings.Map(func(r rune) rune {
+			if isControl(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return s, changes
+}
+
+// truncateHTML returns the first size bytes at most of the HTML document in s, without splitting a multi-byte character.
+func truncateHTML(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseHTMLMalformed(t *testing.T) {
+	b, err := os.ReadFile("testdata/malformed.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseHTML(string(b))
+	if err != nil {
+		t.Fatalf("parseHTML failed on the malformed fixture: %s", err)
+	}
+	if got := doc.Find("title").Text(); got != "Broken" {
+		t.Errorf("title = %q, want %q", got, "Broken")
+	}
+	if doc.Find("div.body.markup").Length() != 1 {
+		t.Error("the body markup is missing")
+	}
+	if href, _ := doc.Find("a").Attr("href"); href != "https://example.com/?a=1&b=2" {
+		t.Errorf("link href = %q", href)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		selector string
+		want     string
+	}{
+		{"unclosed tags", `<div><p>one<p>two`, "p", "onetwo"},
+		{"misnested tags", `<p><b>bold <i>both</b> italic</i></p>`, "b", "bold both"},
+		{"stray end tags", `</div></span><p>text</p></table>`, "p", "text"},
+		{"nul byte", "<p>a\x00b</p>", "p", "ab"},
+		{"invalid utf-8", "<p>a\xffb</p>", "p", "a\xffb"},
+		{"deep nesting", strings.Repeat("<div>", 1000) + "<p>deep</p>", "p", "deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseHTML(tt.html)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.Find(tt.selector).Text(); got != tt.want {
+				t.Errorf("text of %s = %q, want %q", tt.selector, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreloadsFromMalformedPage(t *testing.T) {
+	page, err := os.ReadFile("testdata/malformed-page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the control characters in the data of the page make it fail to decode as is
+	if _, err := decodePreloads(string(page)); err == nil {
+		t.Fatal("the malformed page decoded without a cleanup")
+	}
+
+	rawJSON, err := preloadsFromPage(page)
+	if err != nil {
+		t.Fatalf("preloadsFromPage failed on the malformed page: %s", err)
+	}
+	p, err := rawJSON.ToPost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Slug != "a-post" || !strings.HasPrefix(p.Title, "A") || !strings.Contains(p.BodyHTML, "Body text") {
+		t.Errorf("got post %q (%s) with body %q", p.Title, p.Slug, p.BodyHTML)
+	}
+	if rawJSON.cleanup == "" {
+		t.Error("the cleanup of the page is not reported")
+	}
+}
+
+func TestCleanHTML(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           string
+		want        string
+		wantChanges int
+	}{
+		{"well-formed", "<p>text\tand\r\nlines</p>", "<p>text\tand\r\nlines</p>", 0},
+		{"control characters", "<p>a\x00b\x01c\x7f</p>", "<p>abc</p>", 1},
+		{"invalid utf-8", "<p>a\xffb</p>", "<p>a�b</p>", 1},
+		{"both", "<p>a\xff\x02b</p>", "<p>a�b</p>", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changes := cleanHTML(tt.s)
+			if got != tt.want || len(changes) != tt.wantChanges {
+				t.Errorf("cleanHTML(%q) = %q, %q, want %q with %d changes", tt.s, got, changes, tt.want, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+		want string
+	}{
+		{"<p>short</p>", 100, "<p>short</p>"},
+		{"<p>text</p>", 4, "<p>t"},
+		{"<p>é</p>", 4, "<p>"},
+		{"<p>é</p>", 5, "<p>é"},
+	}
+	for _, tt := range tests {
+		if got := truncateHTML(tt.s, tt.size); got != tt.want {
+			t.Errorf("truncateHTML(%q, %d) = %q, want %q", tt.s, tt.size, got, tt.want)
+		}
+	}
+}
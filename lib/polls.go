@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Poll represents a poll embedded in a Substack post.
+type Poll struct {
+	Id       int          `json:"id"`
+	Question string       `json:"question"`
+	Options  []PollOption `json:"options"`
+}
+
+// PollOption represents one of the answers of a Poll, with the votes it got.
+type PollOption struct {
+	Id    int    `json:"id"`
+	Label string `json:"label"`
+	Votes int    `json:"votes"`
+}
+
+// ToHTML renders the Poll as a static HTML block, listing the votes and percentage of each option.
+func (p *Poll) ToHTML() string {
+	total := 0
+	for _, o := range p.Options {
+		total += o.Votes
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<div class=\"poll\">\n")
+	fmt.Fprintf(&sb, "<p><strong>Poll: %s</strong></p>\n<ul>\n", html.EscapeString(p.Question))
+	for _, o := range p.Options {
+		percentage := 0.0
+		if total > 0 {
+			percentage = float64(o.Votes) * 100 / float64(total)
+		}
+		fmt.Fprintf(&sb, "<li>%s: %d votes (%.0f%%)</li>\n", html.EscapeString(o.Label), o.Votes, percentage)
+	}
+	fmt.Fprintf(&sb, "</ul>\n<p>%d votes in total</p>\n</div>", total)
+	return sb.String()
+}
+
+// renderPolls replaces the poll placeholders of the HTML body with the static representation of the matching polls.
+// Placeholders without a matching poll are left untouched.
+func renderPolls(bodyHTML string, polls []Poll) (string, error) {
+	doc, err := parseHTML(bodyHTML)
+	if err != nil {
+		return "", err
+	}
+
+	pollsById := make(map[int]Poll, len(polls))
+	for _, p := range polls {
+		pollsById[p.Id] = p
+	}
+
+	doc.Find(".poll-embed").Each(func(i int, s *goquery.Selection) {
+		var attrs struct {
+			Id int `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(s.AttrOr("data-attrs", "")), &attrs); err != nil {
+			return
+		}
+		if poll, ok := pollsById[attrs.Id]; ok {
+			s.ReplaceWithHtml(poll.ToHTML())
+		}
+	})
+
+	return doc.Find("body").Html()
+}
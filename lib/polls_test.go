@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtractPostPoll(t *testing.T) {
+	page, err := os.ReadFile("testdata/poll-post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(preloadsPage(t, json.RawMessage(page))))
+	}))
+	defer srv.Close()
+
+	p, err := NewExtractor(NewFetcher(WithRatePerSecond(100))).ExtractPost(context.Background(), srv.URL+"/p/poll-post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Polls) != 1 || p.Polls[0].Question != "Tabs or spaces?" || len(p.Polls[0].Options) != 3 {
+		t.Fatalf("got polls %+v", p.Polls)
+	}
+	if o := p.Polls[0].Options[1]; o.Label != "Spaces" || o.Votes != 10 {
+		t.Errorf("got option %+v, want Spaces with 10 votes", o)
+	}
+
+	for _, want := range []string{
+		"<p>Tell me.</p><div class=\"poll\">",
+		"<strong>Poll: Tabs or spaces?</strong>",
+		"<li>Tabs: 30 votes (75%)</li>",
+		"<li>Spaces: 10 votes (25%)</li>",
+		"<li>Both: 0 votes (0%)</li>",
+		"<p>40 votes in total</p>",
+		"</div><p>Thanks!</p>",
+	} {
+		if !strings.Contains(p.BodyHTML, want) {
+			t.Errorf("the body doesn't contain %s: %s", want, p.BodyHTML)
+		}
+	}
+	if strings.Contains(p.BodyHTML, "poll-embed") {
+		t.Error("the poll placeholder is left in the body")
+	}
+}
+
+func TestPollToHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []PollOption
+		want    []string
+	}{
+		{"no votes", []PollOption{{Label: "A"}, {Label: "B"}}, []string{"<li>A: 0 votes (0%)</li>", "<p>0 votes in total</p>"}},
+		{"rounded", []PollOption{{Label: "A", Votes: 1}, {Label: "B", Votes: 2}}, []string{"<li>A: 1 votes (33%)</li>", "<li>B: 2 votes (67%)</li>"}},
+		{"escaped", []PollOption{{Label: "<b>A</b>", Votes: 1}}, []string{"<li>&lt;b&gt;A&lt;/b&gt;: 1 votes (100%)</li>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := Poll{Question: "Q", Options: tt.options}
+			got := poll.ToHTML()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("ToHTML() = %s, want %s in it", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/k3a/html2text"
+)
+
+// jsonLinePost is the record of a post written by ToJSONLine: all its fields, plus its body as plain text.
+type jsonLinePost struct {
+	Post
+	BodyText string `json:"body_text"`
+}
+
+// ToJSONLine converts the Post to a JSON object on a single line, with all its fields plus its body as plain text,
+// as written to JSON Lines files.
+func (p *Post) ToJSONLine() (string, error) {
+	b, err := json.Marshal(jsonLinePost{Post: *p, BodyText: html2text.HTML2TextWithOptions(p.BodyHTML, html2text.WithUnixLineBreaks())})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// PostsExporter writes many posts to a single JSON Lines file, one post per line, as written by ToJSONLine.
+// The posts are streamed to the file as they are written, so memory usage doesn't grow with the archive.
+type PostsExporter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewPostsExporter creates the file at path for exporting posts, truncating it if it already exists.
+func NewPostsExporter(path string) (*PostsExporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &PostsExporter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// OpenPostsExporter opens the file at path for exporting posts, appending them to it if it already exists,
+// e.g. to export an archive in batches.
+func OpenPostsExporter(path string) (*PostsExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &PostsExporter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Write appends the post to the export file.
+func (x *PostsExporter) Write(post Post) error {
+	line, err := post.ToJSONLine()
+	if err != nil {
+		return err
+	}
+	if _, err := x.w.WriteString(line + "\n"); err != nil {
+		return err
+	}
+	return x.w.Flush()
+}
+
+// Close flushes the pending posts and closes the export file.
+func (x *PostsExporter) Close() error {
+	if err := x.w.Flush(); err != nil {
+		x.f.Close()
+		return err
+	}
+	return x.f.Close()
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// probeTimeout is the maximum time spent on each check of Probe, retries included.
+const probeTimeout = 15 * time.Second
+
+// CheckResult is the outcome of a single diagnostic check.
+type CheckResult struct {
+	Name string `json:"name"`
+	Pass bool   `json:"pass"`
+	// Details explains the outcome, e.g. the error which made the check fail.
+	Details string `json:"details,omitempty"`
+}
+
+// ProbeResult reports whether a website is a Substack publication, and which access methods work on it.
+type ProbeResult struct {
+	Url        string `json:"url"`
+	IsSubstack bool   `json:"is_substack"`
+	// Preloads is true if the home page embeds the Substack page data, which posts are extracted from.
+	Preloads bool `json:"preloads"`
+	// API is true if the Substack API answers under the same domain.
+	API bool `json:"api"`
+	// Sitemap is true if the sitemap, which the archive is listed from, is available.
+	Sitemap bool `json:"sitemap"`
+	// Feed is true if the RSS feed is available.
+	Feed bool `json:"feed"`
+	// Name is the name of the publication, if found in the page data.
+	Name string `json:"name,omitempty"`
+	// Checks lists the outcome of each check, in the order they were run.
+	Checks []CheckResult `json:"checks"`
+}
+
+// Probe checks whether the website at pubUrl, e.g. a custom domain, is a Substack publication,
+// and which of the methods used to download it work: the page data, the API, the sitemap, and the RSS feed.
+// Every check is independent, so a failure is reported as false rather than as an error.
+func (e *Extractor) Probe(ctx context.Context, pubUrl string) (ProbeResult, error) {
+	u, err := url.Parse(pubUrl)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	result := ProbeResult{Url: pubUrl}
+
+	check := func(name string, fn func(ctx context.Context) error) bool {
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		checkResult := CheckResult{Name: name, Pass: true}
+		if err := fn(ctx); err != nil {
+			checkResult.Pass = false
+			checkResult.Details = err.Error()
+		}
+		result.Checks = append(result.Checks, checkResult)
+		return checkResult.Pass
+	}
+
+	result.Preloads = check("preloads", func(ctx context.Context) error {
+		pub, err := e.ExtractPublication(ctx, pubUrl)
+		if err != nil {
+			return err
+		}
+		if pub.Id == 0 {
+			return errors.New("no publication in the page data")
+		}
+		result.Name = pub.Name
+		return nil
+	})
+	result.API = check("api", func(ctx context.Context) error {
+		var archive []json.RawMessage
+		return e.fetchJSON(ctx, u.JoinPath("api", "v1", "archive").String()+"?sort=new&limit=1", &archive)
+	})
+	result.Sitemap = check("sitemap", func(ctx context.Context) error {
+		return e.probeURL(ctx, u.JoinPath("sitemap.xml").String())
+	})
+	result.Feed = check("feed", func(ctx context.Context) error {
+		return e.probeURL(ctx, u.JoinPath("feed").String())
+	})
+
+	result.IsSubstack = result.Preloads || result.API
+	return result, nil
+}
+
+// probeURL checks that the URL can be fetched.
+func (e *Extractor) probeURL(ctx context.Context, url string) error {
+	body, err := e.fetcher.FetchURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	body.Close()
+	return nil
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ProfilePublications holds the publications related to a Substack user.
+type ProfilePublications struct {
+	// Writes are the publications the user writes for, as author or contributor.
+	Writes []Publication `json:"writes"`
+	// Subscriptions are the publications the user subscribes to, if their profile lists them publicly.
+	Subscriptions []Publication `json:"subscriptions"`
+}
+
+// profilePublicationsResponse is the subset of a user's public profile listing their publications.
+type profilePublicationsResponse struct {
+	PublicationUsers []struct {
+		Publication Publication `json:"publication"`
+	} `json:"publicationUsers"`
+	Subscriptions []struct {
+		Publication Publication `json:"publication"`
+	} `json:"subscriptions"`
+}
+
+// GetProfilePublications returns the publications the user writes for and subscribes to, from their public profile.
+// The user can be given as a handle, with or without the leading "@", or as a profile URL (e.g. https://substack.com/@handle).
+func (e *Extractor) GetProfilePublications(ctx context.Context, user string) (ProfilePublications, error) {
+	handle := profileHandle(user)
+	if handle == "" {
+		return ProfilePublications{}, fmt.Errorf("invalid user: %s", user)
+	}
+
+	var res profilePublicationsResponse
+	profileUrl := fmt.Sprintf("%s/api/v1/user/%s/public_profile", substackBaseUrl, url.PathEscape(handle))
+	if err := e.fetchJSON(ctx, profileUrl, &res); err != nil {
+		return ProfilePublications{}, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+
+	pubs := ProfilePublications{Writes: []Publication{}, Subscriptions: []Publication{}}
+	for _, pu := range res.PublicationUsers {
+		pubs.Writes = append(pubs.Writes, pu.Publication)
+	}
+	for _, s := range res.Subscriptions {
+		pubs.Subscriptions = append(pubs.Subscriptions, s.Publication)
+	}
+	return pubs, nil
+}
+
+// profileHandle returns the handle of the user from a handle or a profile URL.
+// e.g. https://substack.com/@handle -> handle
+func profileHandle(user string) string {
+	user = strings.TrimSpace(user)
+	if u, err := url.Parse(user); err == nil && u.Host != "" {
+		user = strings.Trim(u.Path, "/")
+		if i := strings.LastIndex(user, "/"); i >= 0 {
+			user = user[i+1:]
+		}
+	}
+	return strings.TrimPrefix(user, "@")
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Publication represents the metadata of a Substack publication.
+type Publication struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Subdomain    string `json:"subdomain"`
+	CustomDomain string `json:"custom_domain"`
+	HeroText     string `json:"hero_text"`
+	LogoUrl      string `json:"logo_url"`
+	AuthorId     int    `json:"author_id"`
+	AuthorName   string `json:"author_name"`
+	AuthorPhoto  string `json:"author_photo_url"`
+	Language     string `json:"language"`
+	// PostCount is not part of the page data: it is up to the caller to set it, if known.
+	PostCount int `json:"post_count,omitempty"`
+}
+
+// PublicationWrapper wraps a Publication object for JSON unmarshaling.
+type PublicationWrapper struct {
+	Pub Publication `json:"pub"`
+}
+
+// ToJSON converts the Publication to an indented JSON string.
+func (p *Publication) ToJSON() (string, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// WriteToFile writes the Publication's metadata to a JSON file.
+func (p *Publication) WriteToFile(path string) error {
+	content, err := p.ToJSON()
+	if err != nil {
+		return err
+	}
+	return writeFile(path, content)
+}
+
+// ExtractPublication extracts the metadata of the publication from any of its pages,
+// e.g. its homepage or one of its posts.
+func (e *Extractor) ExtractPublication(ctx context.Context, pageUrl string) (Publication, error) {
+	rawJSON, _, err := e.extractPreloads(ctx, pageUrl)
+	if err != nil {
+		return Publication{}, fmt.Errorf("failed to fetch page: %s", err)
+	}
+
+	var wrapper PublicationWrapper
+	err = json.Unmarshal([]byte(rawJSON.str), &wrapper)
+	if err != nil {
+		return Publication{}, fmt.Errorf("failed to fetch page: %s", err)
+	}
+
+	return wrapper.Pub, nil
+}
+
+// sessionWrapper holds the reader data of the page, which is null for anonymous visitors.
+type sessionWrapper struct {
+	User *struct {
+		Id int `json:"id"`
+	} `json:"user"`
+}
+
+// IsAuthenticated reports whether the page at pageUrl is served to a logged in reader,
+// i.e. whether the Fetcher's cookie, if any, is recognized by Substack.
+func (e *Extractor) IsAuthenticated(ctx context.Context, pageUrl string) (bool, error) {
+	rawJSON, _, err := e.extractPreloads(ctx, pageUrl)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch page: %s", err)
+	}
+
+	var wrapper sessionWrapper
+	err = json.Unmarshal([]byte(rawJSON.str), &wrapper)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch page: %s", err)
+	}
+
+	return wrapper.User != nil, nil
+}
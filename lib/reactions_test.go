@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractPostReactions(t *testing.T) {
+	page, err := os.ReadFile("testdata/reactions-post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(preloadsPage(t, json.RawMessage(page))))
+	}))
+	defer srv.Close()
+
+	p, err := NewExtractor(NewFetcher(WithRatePerSecond(100))).ExtractPost(context.Background(), srv.URL+"/p/liked-post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ReactionCount != 57 {
+		t.Errorf("ReactionCount = %d, want 57", p.ReactionCount)
+	}
+	if want := map[string]int{"❤": 52, "🔥": 5}; !reflect.DeepEqual(p.Reactions, want) {
+		t.Errorf("Reactions = %v, want %v", p.Reactions, want)
+	}
+
+	tests := []struct {
+		name    string
+		post    Post
+		want    string
+		wantOut string
+	}{
+		{"with reactions", p, `"reactions":{"❤":52,"🔥":5}`, ""},
+		{"without reactions", Post{Title: "Post"}, `"reaction_count":0`, `"reactions"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.post.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("ToJSON() = %s, want %s in it", got, tt.want)
+			}
+			if tt.wantOut != "" && strings.Contains(got, tt.wantOut) {
+				t.Errorf("ToJSON() = %s, want no %s in it", got, tt.wantOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"golang.org/x/time/rate"
+)
+
+// ReadwiseSaveURL is the endpoint of the Readwise Reader API which saves a document to the library.
+const ReadwiseSaveURL = "https://readwise.io/api/v3/save/"
+
+// readwiseRequestsPerMinute is the number of documents the Readwise Reader API allows to save per minute.
+const readwiseRequestsPerMinute = 50
+
+// ErrReadwiseUnauthorized is returned by ReadwiseClient.Save when the Readwise access token is rejected.
+var ErrReadwiseUnauthorized = errors.New("the Readwise access token was rejected: get a valid one at https://readwise.io/access_token")
+
+// ReadwiseDocument is a document saved to Readwise Reader.
+type ReadwiseDocument struct {
+	URL           string   `json:"url"`
+	HTML          string   `json:"html,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	ImageURL      string   `json:"image_url,omitempty"`
+	PublishedDate string   `json:"published_date,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	// ShouldCleanHTML asks Readwise to extract the article from HTML, as it does for the pages it fetches itself.
+	ShouldCleanHTML bool   `json:"should_clean_html"`
+	SavedUsing      string `json:"saved_using,omitempty"`
+}
+
+// ReadwiseDocument returns the Post as a document for Readwise Reader, with its HTML body as the content.
+func (p *Post) ReadwiseDocument() ReadwiseDocument {
+	return ReadwiseDocument{
+		URL:           p.CanonicalUrl,
+		HTML:          p.BodyHTML,
+		Title:         p.Title,
+		Author:        p.Author(),
+		Summary:       p.Description,
+		ImageURL:      p.CoverImage,
+		PublishedDate: p.PostDate,
+		Tags:          p.Tags(),
+		SavedUsing:    "sbstck-dl",
+	}
+}
+
+// ReadwiseClient saves documents to Readwise Reader through its API, within its rate limit.
+type ReadwiseClient struct {
+	// Token is the Readwise access token.
+	Token string
+	// URL is the endpoint the documents are saved to, ReadwiseSaveURL by default.
+	URL         string
+	Client      *http.Client
+	RateLimiter *rate.Limiter
+}
+
+// NewReadwiseClient creates a ReadwiseClient with the access token, sending its requests with client.
+// If client is nil, http.DefaultClient is used.
+func NewReadwiseClient(token string, client *http.Client) *ReadwiseClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ReadwiseClient{
+		Token:       token,
+		URL:         ReadwiseSaveURL,
+		Client:      client,
+		RateLimiter: rate.NewLimiter(rate.Every(time.Minute/readwiseRequestsPerMinute), 1),
+	}
+}
+
+// Save saves the document to Readwise Reader. It reports whether the document was created,
+// as opposed to being already in the library, which leaves it untouched.
+// Too many requests, server errors and network errors are retried with backoff;
+// an invalid token fails with ErrReadwiseUnauthorized.
+func (c *ReadwiseClient) Save(ctx context.Context, doc ReadwiseDocument) (created bool, err error) {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+
+	operation := func() error {
+		if err := c.RateLimiter.Wait(ctx); err != nil {
+			return backoff.Permanent(err)
+		}
+		created, err = c.save(ctx, body)
+		var fetchErr *FetchError
+		if errors.As(err, &fetchErr) && !fetchErr.TooManyRequests && fetchErr.StatusCode < 500 {
+			return backoff.Permanent(err) // e.g. 400: retrying won't help
+		}
+		if errors.Is(err, ErrReadwiseUnauthorized) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	err = backoff.Retry(operation, backoff.WithContext(makeDefaultBackoff(), ctx))
+	return created, err
+}
+
+// save sends a single request saving the JSON document body.
+func (c *ReadwiseClient) save(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Token "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", DefaultUserAgent)
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	switch res.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusOK:
+		return false, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, ErrReadwiseUnauthorized
+	case http.StatusTooManyRequests:
+		retryAfter := defaultRetryAfter
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			retryAfter = seconds
+		}
+		// the API resets its limit after Retry-After: wait for it rather than for the backoff
+		timer := time.NewTimer(time.Duration(retryAfter) * time.Second)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false, backoff.Permanent(ctx.Err())
+		case <-timer.C:
+		}
+		return false, &FetchError{StatusCode: res.StatusCode, TooManyRequests: true, RetryAfter: retryAfter}
+	}
+	return false, fmt.Errorf("saving to Readwise: %w", &FetchError{StatusCode: res.StatusCode})
+}
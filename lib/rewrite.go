@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// RewriteDomain rewrites the Post's canonical URL and the links to posts (/p/ paths) in its body
+// from the domain from to the domain to, e.g. from https://example.substack.com to https://example.com.
+// If from has no scheme, both http and https URLs are rewritten; if to has no scheme, https is used.
+// Other URLs, like the ones of images and files, are left untouched.
+func (p *Post) RewriteDomain(from string, to string) error {
+	prefixes := []string{strings.TrimSuffix(from, "/")}
+	if !strings.Contains(from, "://") {
+		prefixes = []string{"https://" + prefixes[0], "http://" + prefixes[0]}
+	}
+	to = strings.TrimSuffix(to, "/")
+	if !strings.Contains(to, "://") {
+		to = "https://" + to
+	}
+
+	rewrite := func(u string) string {
+		for _, prefix := range prefixes {
+			if u == prefix || strings.HasPrefix(u, prefix+"/") {
+				return to + strings.TrimPrefix(u, prefix)
+			}
+		}
+		return u
+	}
+
+	p.CanonicalUrl = rewrite(p.CanonicalUrl)
+
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return err
+	}
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href := s.AttrOr("href", "")
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(href, prefix+"/p/") {
+				s.SetAttr("href", rewrite(href))
+				return
+			}
+		}
+	})
+	p.BodyHTML, err = doc.Find("body").Html()
+	return err
+}
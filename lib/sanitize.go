@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// SanitizeRule replaces every match of Pattern with Replacement.
+// Replacement can reference the submatches of Pattern (e.g. $1), as in regexp.ReplaceAllString.
+type SanitizeRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultSanitizeRules lists the rules applied by default by a Sanitizer.
+// They redact the signed tokens (JWTs) Substack embeds in links for logged in readers.
+var DefaultSanitizeRules = []SanitizeRule{
+	{Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), Replacement: "REDACTED"},
+}
+
+// DefaultSanitizeQueryParams lists the query parameters removed by default from URLs by a Sanitizer.
+// They identify the reader (referral codes, gift and access tokens) rather than the content.
+var DefaultSanitizeQueryParams = []string{"r", "token", "gift", "giftToken", "access_token", "sharetoken"}
+
+// rawPrivateKeys lists the top-level keys of the page data which describe the reader rather than the post,
+// e.g. their account and subscription, removed from the raw data of the posts by a Sanitizer.
+var rawPrivateKeys = []string{"user", "userSettings", "subscription", "subscriptions", "pubSubscription", "pledge", "pledges", "readerReferralCode"}
+
+// urlRegex matches the http(s) URLs in a text or HTML document.
+var urlRegex = regexp.MustCompile(`https?://[^\s"'<>()]+`)
+
+// querySeparatorRegex matches the separators between query parameters, either raw or HTML-escaped.
+var querySeparatorRegex = regexp.MustCompile(`&amp;|&`)
+
+// Sanitizer scrubs reader-specific data, like session tokens and referral codes, from post contents.
+type Sanitizer struct {
+	Rules       []SanitizeRule
+	QueryParams []string
+}
+
+// NewSanitizer creates a new Sanitizer with the default rules and query parameters.
+func NewSanitizer() *Sanitizer {
+	return &Sanitizer{
+		Rules:       DefaultSanitizeRules,
+		QueryParams: DefaultSanitizeQueryParams,
+	}
+}
+
+// Sanitize removes the configured query parameters from all the URLs in content, then applies the configured rules.
+func (s *Sanitizer) Sanitize(content string) string {
+	if len(s.QueryParams) > 0 {
+		content = urlRegex.ReplaceAllStringFunc(content, s.sanitizeURL)
+	}
+	for _, rule := range s.Rules {
+		content = rule.Pattern.ReplaceAllString(content, rule.Replacement)
+	}
+	return content
+}
+
+// SanitizePost sanitizes the body and the URLs of the post, and its raw data, from which the reader data is removed.
+func (s *Sanitizer) SanitizePost(p *Post) {
+	p.BodyHTML = s.Sanitize(p.BodyHTML)
+	p.CanonicalUrl = s.Sanitize(p.CanonicalUrl)
+	p.CoverImage = s.Sanitize(p.CoverImage)
+	p.Description = s.Sanitize(p.Description)
+	if p.raw != "" {
+		p.raw = s.sanitizeRaw(p.raw)
+	}
+}
+
+// sanitizeRaw removes the reader data (rawPrivateKeys) from the raw JSON data of a post, then sanitizes the rest.
+func (s *Sanitizer) sanitizeRaw(raw string) string {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &data); err == nil {
+		for _, key := range rawPrivateKeys {
+			delete(data, key)
+		}
+		if b, err := json.Marshal(data); err == nil {
+			raw = string(b)
+		}
+	}
+	return s.Sanitize(raw)
+}
+
+// sanitizeURL removes the configured query parameters from rawUrl, keeping the order of the remaining ones.
+// rawUrl can be HTML-escaped, in which case the escaping of the separators is preserved.
+func (s *Sanitizer) sanitizeURL(rawUrl string) string {
+	base, query, found := strings.Cut(rawUrl, "?")
+	if !found {
+		return rawUrl
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+
+	separator := "&"
+	if strings.Contains(query, "&amp;") {
+		separator = "&amp;"
+	}
+
+	var kept []string
+	for _, param := range querySeparatorRegex.Split(query, -1) {
+		key, _, _ := strings.Cut(param, "=")
+		if param == "" || s.isSanitizedParam(key) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+
+	sanitized := base
+	if len(kept) > 0 {
+		sanitized += "?" + strings.Join(kept, separator)
+	}
+	if hasFragment {
+		sanitized += "#" + fragment
+	}
+	return sanitized
+}
+
+func (s *Sanitizer) isSanitizedParam(key string) bool {
+	for _, p := range s.QueryParams {
+		if key == p {
+			return true
+		}
+	}
+	return false
+}
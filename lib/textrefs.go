@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/k3a/html2text"
+)
+
+// ToTextWithReferences converts the Post's HTML body to plain text, like ToText, but keeps the link targets:
+// each link is followed by a numbered reference, e.g. "text[1]", and the references are listed at the end of the text,
+// similar to lynx -dump. Links pointing to the same URL share the same reference.
+func (p *Post) ToTextWithReferences(withTitle bool) (string, error) {
+	doc, err := parseHTML(p.BodyHTML)
+	if err != nil {
+		return "", err
+	}
+	body := doc.Find("body")
+
+	var refs []string
+	refIndex := make(map[string]int)
+	body.Find("a").Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href != "" && !strings.HasPrefix(href, "#") {
+			n, ok := refIndex[href]
+			if !ok {
+				refs = append(refs, href)
+				n = len(refs)
+				refIndex[href] = n
+			}
+			s.AppendHtml(fmt.Sprintf("[%d]", n))
+		}
+		s.ReplaceWithSelection(s.Contents())
+	})
+
+	bodyHTML, err := body.Html()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if withTitle {
+		sb.WriteString(p.Title + "\n\n")
+	}
+	sb.WriteString(html2text.HTML2Text(bodyHTML))
+	if len(refs) > 0 {
+		sb.WriteString("\n\nReferences\n\n")
+		for i, ref := range refs {
+			fmt.Fprintf(&sb, "[%d] %s\n", i+1, ref)
+		}
+	}
+	return sb.String(), nil
+}
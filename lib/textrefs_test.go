@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestToTextWithReferences(t *testing.T) {
+	body, err := os.ReadFile("testdata/link-references-body.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := Post{Title: "Links", BodyHTML: string(body)}
+	got, err := post.ToTextWithReferences(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Links\n\n",
+		"Read the first post[1] and the second one[2].",
+		"Then go back to the first post[1] or jump to the note.",
+		"References\n\n[1] https://example.com/first\n[2] https://example.com/second\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ToTextWithReferences() is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "[3]") {
+		t.Errorf("ToTextWithReferences() has a reference to the anchor link:\n%s", got)
+	}
+
+	post.BodyHTML = "<p>No links here.</p>"
+	got, err = post.ToTextWithReferences(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "References") {
+		t.Errorf("ToTextWithReferences() lists references for a post without links:\n%s", got)
+	}
+}
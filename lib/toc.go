@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TOCMinHeadings is the minimum number of headings a post needs to get a table of contents.
+const TOCMinHeadings = 3
+
+// headingIdRegex matches the runs of characters replaced by a dash in the ids given to the headings.
+var headingIdRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// AddTableOfContents injects a table of contents, linking to the h2 and h3 headings of the body, at its top.
+// Headings without an id get one derived from their text, in the style of the anchors GitHub generates for Markdown,
+// so that the links also resolve once the post is converted to Markdown.
+// Bodies with fewer than minHeadings headings are returned unchanged.
+func AddTableOfContents(bodyHTML string, minHeadings int) (string, error) {
+	doc, err := parseHTML(bodyHTML)
+	if err != nil {
+		return "", err
+	}
+	body := doc.Find("body")
+
+	headings := body.Find("h2, h3")
+	if headings.Length() < minHeadings {
+		return bodyHTML, nil
+	}
+
+	used := make(map[string]bool)
+	body.Find("[id]").Each(func(i int, s *goquery.Selection) {
+		used[s.AttrOr("id", "")] = true
+	})
+
+	// the h3 headings are nested in the item of the h2 heading before them, if any
+	var sb strings.Builder
+	sb.WriteString("<nav class=\"toc\">\n<ul>\n")
+	itemOpen, subListOpen := false, false
+	headings.Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			return
+		}
+		id, ok := s.Attr("id")
+		if !ok || id == "" {
+			id = uniqueHeadingId(text, used)
+			s.SetAttr("id", id)
+		}
+		link := fmt.Sprintf("<a href=\"#%s\">%s</a>", html.EscapeString(id), html.EscapeString(text))
+		if goquery.NodeName(s) == "h3" && itemOpen {
+			if !subListOpen {
+				sb.WriteString("\n<ul>\n")
+				subListOpen = true
+			}
+			fmt.Fprintf(&sb, "<li>%s</li>\n", link)
+			return
+		}
+		if subListOpen {
+			sb.WriteString("</ul>\n")
+			subListOpen = false
+		}
+		if itemOpen {
+			sb.WriteString("</li>\n")
+		}
+		fmt.Fprintf(&sb, "<li>%s", link)
+		itemOpen = goquery.NodeName(s) == "h2"
+		if !itemOpen {
+			sb.WriteString("</li>\n")
+		}
+	})
+	if subListOpen {
+		sb.WriteString("</ul>\n")
+	}
+	if itemOpen {
+		sb.WriteString("</li>\n")
+	}
+	sb.WriteString("</ul>\n</nav>\n")
+
+	content, err := body.Html()
+	if err != nil {
+		return "", err
+	}
+	return sb.String() + content, nil
+}
+
+// uniqueHeadingId returns an id for the heading with the given text which is not among the used ones,
+// and marks it as used. e.g. "Why it matters?" -> why-it-matters
+func uniqueHeadingId(text string, used map[string]bool) string {
+	base := strings.Trim(headingIdRegex.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for i := 1; used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[id] = true
+	return id
+}
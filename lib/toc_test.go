@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAddTableOfContents(t *testing.T) {
+	body, err := os.ReadFile("testdata/toc-body.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := AddTableOfContents(string(body), TOCMinHeadings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const toc = `<nav class="toc">
+<ul>
+<li><a href="#why-it-matters">Why it matters?</a>
+<ul>
+<li><a href="#a-detail">A detail</a></li>
+<li><a href="#custom">Another detail</a></li>
+</ul>
+</li>
+<li><a href="#why-it-matters-1">Why it matters?</a></li>
+<li><a href="#conclusion">Conclusion</a></li>
+</ul>
+</nav>
+<p>Intro.</p>`
+	if !strings.HasPrefix(got, toc) {
+		t.Errorf("AddTableOfContents() doesn't start with the table of contents:\n%s", got)
+	}
+	for _, want := range []string{
+		`<h2 id="why-it-matters">Why it matters?</h2>`,
+		`<h3 id="a-detail">A detail</h3>`,
+		`<h3 id="custom">Another detail</h3>`,
+		`<h2 id="why-it-matters-1">Why it matters?</h2>`,
+		`<h2 id="conclusion">Conclusion</h2>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("AddTableOfContents() is missing the heading %s:\n%s", want, got)
+		}
+	}
+
+	short := "<h2>One</h2><p>Text</p><h2>Two</h2>"
+	if got, err := AddTableOfContents(short, TOCMinHeadings); err != nil || got != short {
+		t.Errorf("AddTableOfContents() = %q, %v; want the body unchanged", got, err)
+	}
+}
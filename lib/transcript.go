@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// TranscriptSegment is a part of the transcript of a podcast post.
+type TranscriptSegment struct {
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Transcript is the transcript of a podcast post, as a list of segments.
+// It is decoded from either a plain string, an object with a text or a segments field, or a list of segments.
+type Transcript []TranscriptSegment
+
+// UnmarshalJSON decodes the transcript from any of the shapes it is found in.
+func (t *Transcript) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = textTranscript(text)
+		return nil
+	}
+
+	var segments []TranscriptSegment
+	if err := json.Unmarshal(data, &segments); err == nil {
+		*t = segments
+		return nil
+	}
+
+	var obj struct {
+		Text     string              `json:"text"`
+		Segments []TranscriptSegment `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		// an unknown shape is not worth failing the whole post
+		*t = nil
+		return nil
+	}
+	if len(obj.Segments) > 0 {
+		*t = obj.Segments
+	} else {
+		*t = textTranscript(obj.Text)
+	}
+	return nil
+}
+
+// textTranscript returns the transcript made of the given text, or nil if it is empty.
+func textTranscript(text string) Transcript {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return Transcript{{Text: text}}
+}
+
+// render returns the transcript as a section in the specified format (html, md, txt, or org).
+// In html, it is a collapsible section.
+func (t Transcript) render(format string) string {
+	var sb strings.Builder
+	switch format {
+	case "html":
+		sb.WriteString("<details>\n<summary>Transcript</summary>\n")
+	case "md":
+		sb.WriteString("## Transcript\n\n")
+	case "org":
+		sb.WriteString("* Transcript\n\n")
+	default:
+		sb.WriteString("Transcript\n\n")
+	}
+	for _, segment := range t {
+		for _, paragraph := range strings.Split(segment.Text, "\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+				continue
+			}
+			if segment.Speaker != "" {
+				paragraph = fmt.Sprintf("%s: %s", segment.Speaker, paragraph)
+			}
+			if format == "html" {
+				fmt.Fprintf(&sb, "<p>%s</p>\n", html.EscapeString(paragraph))
+			} else {
+				sb.WriteString(paragraph + "\n\n")
+			}
+		}
+	}
+	if format == "html" {
+		sb.WriteString("</details>\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractPostTranscript(t *testing.T) {
+	page, err := os.ReadFile("testdata/podcast-post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(preloadsPage(t, json.RawMessage(page))))
+	}))
+	defer srv.Close()
+
+	p, err := NewExtractor(NewFetcher(WithRatePerSecond(100))).ExtractPost(context.Background(), srv.URL+"/p/episode-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Transcript{
+		{Speaker: "Alice", Text: "Welcome to the show.\nToday we talk about Go."},
+		{Speaker: "Bob", Text: "Thanks for having me & <my> book."},
+	}
+	if !reflect.DeepEqual(p.Transcript, want) {
+		t.Fatalf("got transcript %+v, want %+v", p.Transcript, want)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"html", []string{"<details>\n<summary>Transcript</summary>\n", "<p>Alice: Welcome to the show.</p>\n<p>Alice: Today we talk about Go.</p>\n", "<p>Bob: Thanks for having me &amp; &lt;my&gt; book.</p>\n</details>\n"}},
+		{"md", []string{"## Transcript\n\nAlice: Welcome to the show.\n\nAlice: Today we talk about Go.\n\n"}},
+		{"txt", []string{"Transcript\n\nAlice: Welcome to the show.\n\n", "Bob: Thanks for having me & <my> book.\n\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			content, err := p.contentForFormat(tt.format, WriteOptions{Transcript: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("the %s content doesn't contain %q:\n%s", tt.format, want, content)
+				}
+			}
+			without, err := p.contentForFormat(tt.format, WriteOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(without, "Transcript") {
+				t.Errorf("the %s content has the transcript without the option:\n%s", tt.format, without)
+			}
+		})
+	}
+}
+
+func TestTranscriptUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Transcript
+	}{
+		{"string", `"Hello"`, Transcript{{Text: "Hello"}}},
+		{"empty string", `"  "`, nil},
+		{"segments", `[{"speaker":"A","text":"Hi"}]`, Transcript{{Speaker: "A", Text: "Hi"}}},
+		{"object with text", `{"text":"Hello"}`, Transcript{{Text: "Hello"}}},
+		{"object with segments", `{"segments":[{"text":"Hi"}]}`, Transcript{{Text: "Hi"}}},
+		{"unknown shape", `42`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Transcript
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fullBody is the body of the truncated-post fixture, as served by the API.
+var fullBody = "<p>" + strings.Repeat("The whole post, all of it. ", 8) + "</p>"
+
+func TestExtractPostTruncatedBody(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/truncated-post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		edit         func(post map[string]any)
+		wantAPICalls int
+		wantFull     bool
+	}{
+		{"public post behind an interstitial", func(post map[string]any) {}, 1, true},
+		{"paywalled post", func(post map[string]any) { post["should_show_paywall"] = true }, 0, false},
+		{"paid post", func(post map[string]any) { post["audience"] = "only_paid" }, 0, false},
+		{"whole body", func(post map[string]any) { post["body_html"] = fullBody }, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data map[string]map[string]any
+			if err := json.Unmarshal(fixture, &data); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(data["post"])
+			page := preloadsPage(t, data)
+
+			apiCalls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/v1/posts/long-post" {
+					apiCalls++
+					json.NewEncoder(w).Encode(map[string]string{"body_html": fullBody})
+					return
+				}
+				w.Write([]byte(page))
+			}))
+			defer srv.Close()
+
+			e := NewExtractor(NewFetcher(WithRatePerSecond(100)))
+			p, err := e.ExtractPost(context.Background(), srv.URL+"/p/long-post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if apiCalls != tt.wantAPICalls {
+				t.Errorf("got %d requests to the API, want %d", apiCalls, tt.wantAPICalls)
+			}
+			if full := p.BodyHTML == fullBody; full != tt.wantFull {
+				t.Errorf("got body %q, want the whole body: %v", p.BodyHTML, tt.wantFull)
+			}
+		})
+	}
+}
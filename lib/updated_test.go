@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtractPostUpdatedAt(t *testing.T) {
+	page, err := os.ReadFile("testdata/updated-post.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(preloadsPage(t, json.RawMessage(page))))
+	}))
+	defer srv.Close()
+
+	p, err := NewExtractor(NewFetcher(WithRatePerSecond(100))).ExtractPost(context.Background(), srv.URL+"/p/edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const updatedAt = "2024-02-03T17:30:00.000Z"
+	if p.UpdatedAt != updatedAt {
+		t.Fatalf("got updated_at %q, want %q", p.UpdatedAt, updatedAt)
+	}
+
+	j, err := p.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(j, `"updated_at":"`+updatedAt+`"`) {
+		t.Errorf("the JSON output is missing updated_at: %s", j)
+	}
+
+	tests := []struct {
+		preset FrontMatterPreset
+		want   string
+	}{
+		{FrontMatterGeneric, `updated_at: "` + updatedAt + `"`},
+		{FrontMatterHugo, `lastmod: "` + updatedAt + `"`},
+		{FrontMatterJekyll, `last_modified_at: "` + updatedAt + `"`},
+	}
+	for _, tt := range tests {
+		if fm := p.FrontMatterFor(tt.preset); !strings.Contains(fm, tt.want+"\n") {
+			t.Errorf("the %q front matter is missing %s:\n%s", tt.preset, tt.want, fm)
+		}
+	}
+
+	p.UpdatedAt = ""
+	if j, _ := p.ToJSON(); strings.Contains(j, "updated_at") {
+		t.Errorf("the JSON output of a post never edited has updated_at: %s", j)
+	}
+	if fm := p.FrontMatter(); strings.Contains(fm, "updated_at") {
+		t.Errorf("the front matter of a post never edited has updated_at:\n%s", fm)
+	}
+}
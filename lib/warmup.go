@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// cdnMaxRate is the highest rate, in requests per second, suggested by WarmUp for the websites behind a CDN
+// which is known to block the clients sending bursts of requests.
+const cdnMaxRate = rate.Limit(1)
+
+// WarmUpResult reports how the website of a publication answers, to tune the requests before a large download.
+type WarmUpResult struct {
+	StatusCode int
+	// RetryAfter is the wait asked by the server, if it answered with too many requests right away.
+	RetryAfter time.Duration
+	// Server is the value of the Server header of the response.
+	Server string
+	// CDN is the name of the content delivery network serving the website, if recognized.
+	CDN string
+	// Authenticated reports whether the page was served to a logged in reader, i.e. whether the cookie is recognized.
+	Authenticated bool
+	// SuggestedRate is the rate, in requests per second, suggested for the download.
+	// It is lower than the current one when the server asks to slow down or when it is behind a strict CDN.
+	SuggestedRate rate.Limit
+}
+
+// WarmUp sends a single request to the home page at pubUrl, without retrying it, and reports how the server answers:
+// whether it asks to slow down right away, which server and CDN it runs on, and whether the cookie is recognized.
+// An answer with too many requests pauses the Fetcher as asked, and is reported rather than returned as an error.
+func (e *Extractor) WarmUp(ctx context.Context, pubUrl string) (WarmUpResult, error) {
+	currentRate := e.fetcher.RateLimiter.Limit()
+	result := WarmUpResult{SuggestedRate: currentRate}
+
+	if err := e.fetcher.waitPause(ctx); err != nil {
+		return result, err
+	}
+	if err := e.fetcher.RateLimiter.Wait(ctx); err != nil {
+		return result, err
+	}
+	res, err := e.fetcher.fetch(ctx, pubUrl)
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		result.StatusCode = fetchErr.StatusCode
+		if fetchErr.TooManyRequests {
+			result.RetryAfter = time.Duration(fetchErr.RetryAfter) * time.Second
+			result.SuggestedRate = currentRate / 2
+			if result.SuggestedRate < adaptiveMinRate {
+				result.SuggestedRate = adaptiveMinRate
+			}
+			return result, nil
+		}
+		return result, err
+	}
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	result.StatusCode = res.StatusCode
+	result.Server = res.Header.Get("Server")
+	result.CDN = detectCDN(res.Header)
+	if result.CDN == "Cloudflare" && currentRate > cdnMaxRate {
+		result.SuggestedRate = cdnMaxRate
+	}
+
+	page, err := io.ReadAll(res.Body)
+	if err != nil {
+		return result, err
+	}
+	// the page data is only needed to know the reader: a page without it is reported as anonymous
+	if rawJSON, err := preloadsFromPage(page); err == nil {
+		var wrapper sessionWrapper
+		if json.Unmarshal([]byte(rawJSON.str), &wrapper) == nil {
+			result.Authenticated = wrapper.User != nil
+		}
+	}
+	return result, nil
+}
+
+// detectCDN returns the name of the content delivery network which served the response with the header,
+// based on the headers it adds, or an empty string if not recognized.
+func detectCDN(header http.Header) string {
+	server := strings.ToLower(header.Get("Server"))
+	switch {
+	case header.Get("Cf-Ray") != "" || server == "cloudflare":
+		return "Cloudflare"
+	case header.Get("X-Amz-Cf-Id") != "" || server == "cloudfront":
+		return "CloudFront"
+	case header.Get("X-Fastly-Request-Id") != "" || strings.Contains(header.Get("X-Served-By"), "cache-"):
+		return "Fastly"
+	case header.Get("X-Vercel-Id") != "":
+		return "Vercel"
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWriteFileFromInterrupted(t *testing.T) {
+	errInterrupted := errors.New("interrupted")
+	tests := []struct {
+		name     string
+		existing string // the content of the file before the write, if any
+	}{
+		{"new file", ""},
+		{"existing file", "the previous complete version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "post.html")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the write fails halfway through the content
+			r := io.MultiReader(strings.NewReader("<p>the first half"), iotest.ErrReader(errInterrupted))
+			if err := writeFileFrom(path, r); !errors.Is(err, errInterrupted) {
+				t.Fatalf("writeFileFrom returned %v, want the error of the reader", err)
+			}
+
+			b, err := os.ReadFile(path)
+			switch {
+			case tt.existing == "" && !os.IsNotExist(err):
+				t.Errorf("the failed write left a file with %q", b)
+			case tt.existing != "" && string(b) != tt.existing:
+				t.Errorf("the failed write changed the file to %q", b)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != "post.html" {
+					t.Errorf("the failed write left the temporary file %s", e.Name())
+				}
+			}
+		})
+	}
+}